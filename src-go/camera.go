@@ -1,34 +1,300 @@
-//go:build !arm64
-
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/disintegration/imaging"
 )
 
+// Where the camera pictures come from
+const (
+	CameraSource_Rpicam      = "rpicamvid"   //Raspberry Pi camera module (arm64 only)
+	CameraSource_V4L2        = "v4l2"        //USB/V4L2 camera with MJPEG output ("url" is the device, default /dev/video0)
+	CameraSource_MJPEG       = "mjpeg"       //IP camera MJPEG stream ("url" is the http address)
+	CameraSource_Snapshot    = "snapshot"    //IP camera JPEG snapshot ("url" is the http address, fetched "fps" times a second)
+	CameraSource_RTSP        = "rtsp"        //IP camera RTSP stream ("url" is the rtsp address) - needs ffmpeg installed
+	CameraSource_Directory   = "directory"   //JPEG files in a folder, shown in a loop ("url" is the folder) - for testing
+	CameraSource_TestPattern = "testpattern" //color bars (default when not on the Pi)
+)
+
+const defaultCameraFPS = 5
+
+type CamConfig struct {
+	Source   string `json:"source"` //blank = default for the platform
+	URL      string `json:"url"`
+	Rotation int    `json:"rotation"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FPS      int    `json:"fps"` //frames per second for the sources which have to be polled (default 5)
+	// Video clips saved with the gate logs (turned off if both are 0)
+	ClipBeforeSecs int `json:"clip_seconds_before"`
+	ClipAfterSecs  int `json:"clip_seconds_after"`
+	ClipFPS        int `json:"clip_fps"` //frames per second kept for the clips (default 4)
+	// Motion detection for presence events (turned off if missing)
+	Motion *MotionConfig `json:"motion,omitempty"`
+}
+
+// FrameSource is a camera which can be started up to get JPEG frames
+type FrameSource interface {
+	Start() (FrameStream, error)
+}
+
+// FrameStream is a running camera - each frame is a JPEG image
+type FrameStream interface {
+	NextFrame() ([]byte, error)
+	Close()
+}
+
+// Camera owns the frame source: one capture goroutine reads the frames and hands them to everyone watching.
+// The capture starts when somebody wants frames, and stops again a little while after the last one goes away.
 type Camera struct {
+	err      error //camera could not be started up (yet)
+	source   FrameSource
+	rotation int           `json:"-"` //rotation still to be done on each frame (never changes after NewCamera)
+	done     chan struct{} `json:"-"` //closed when the camera is shut down
+	//Shared capture
+	locker   sync.Mutex
+	subs     map[chan []byte]bool
+	running  bool
+	gen      int         //which capture goroutine is the current one
+	stream   FrameStream //stream the current capture is reading (nil while it is not running)
+	streamAt time.Time
+	latest   []byte
+	latestAt time.Time
+	idleAt   time.Time //when the last subscriber went away
+	health   CameraHealth
+	//Clip buffer
+	buffer     *frameBuffer
+	clipBefore time.Duration
+	clipAfter  time.Duration
 }
 
-type CamConfig struct {
-	Rotation int `json:"rotation"`
-	Width    int `json:"width"`
-	Height   int `json:"height"`
+func (cc CamConfig) source() string {
+	if cc.Source == "" {
+		return defaultCameraSource
+	}
+	return cc.Source
+}
+
+func (cc CamConfig) fps() int {
+	if cc.FPS < 1 {
+		return defaultCameraFPS
+	}
+	return cc.FPS
+}
+
+func newFrameSource(cc CamConfig) (FrameSource, error) {
+	switch cc.source() {
+	case CameraSource_Rpicam:
+		return newRpicamSource(cc)
+	case CameraSource_V4L2:
+		return newV4L2Source(cc)
+	case CameraSource_MJPEG:
+		return newMJPEGSource(cc)
+	case CameraSource_Snapshot:
+		return newSnapshotSource(cc)
+	case CameraSource_RTSP:
+		return newRTSPSource(cc)
+	case CameraSource_Directory:
+		return newDirectorySource(cc)
+	case CameraSource_TestPattern:
+		return newTestPatternSource(cc)
+	}
+	return nil, fmt.Errorf("unknown camera source: %s", cc.Source)
 }
 
 func NewCamera(cc CamConfig) (*Camera, error) {
-	C := Camera{}
-	//Template - for testing build on Windows only
-	return &C, nil
+	C := Camera{rotation: cc.Rotation, done: make(chan struct{}), subs: make(map[chan []byte]bool)}
+	if cc.source() == CameraSource_Rpicam && cc.Rotation >= 180 {
+		C.rotation = cc.Rotation - 180 //rpicam-vid flips the picture itself
+	}
+	C.source, C.err = newFrameSource(cc)
+	if C.err != nil {
+		C.health.LastError = C.err.Error()
+		return &C, C.err //bad settings - no point trying again
+	}
+	// The clip buffer is set up here (before anything else can use the camera), and just waits for the camera to start working
+	C.startClipBuffer(cc)
+	// Start it up and see if it is working (then close it down again)
+	// If not, the supervisor keeps trying in the background.
+	err := C.checkSource()
+	if err != nil {
+		fmt.Printf("Unable to start camera service: %v\n", err)
+		C.err = err
+	} else {
+		fmt.Println("Initialized Camera:", cc.source())
+	}
+	go C.supervise(cc)
+	return &C, err
 }
 
 func (C *Camera) Close() {
-
+	select {
+	case <-C.done:
+	default:
+		close(C.done) //stops the capture and the clip buffer
+	}
 }
 
 func (C *Camera) ServeImages(w http.ResponseWriter, req *http.Request, p *Page) {
-
+	if err := C.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	C.serveHttp(w, req)
 }
 
+// TakePicture returns the latest frame (or waits for the first one if the camera was not running)
 func (C *Camera) TakePicture() []byte {
-	return nil
+	if C.Err() != nil {
+		return []byte{}
+	}
+	if frame := C.latestFrame(); frame != nil {
+		return frame
+	} else if C.failing() {
+		return []byte{} //do not hold up the gate waiting on a broken camera
+	}
+	frames := C.subscribe()
+	defer C.unsubscribe(frames)
+	select {
+	case frame, ok := <-frames:
+		if ok {
+			return frame
+		}
+	case <-time.After(cameraFrameWait):
+	}
+	fmt.Println("Unable to get camera frame")
+	return []byte{}
+}
+
+// everyFrame hands a frame to the function every so often, and keeps the camera running until it is closed
+// (used for the clip buffer and motion detection)
+func (C *Camera) everyFrame(every time.Duration, fn func(frame []byte)) {
+	for {
+		if C.Err() == nil {
+			frames := C.subscribe()
+			var last time.Time
+			for running := true; running; {
+				select {
+				case <-C.done:
+					C.unsubscribe(frames)
+					return
+				case frame, ok := <-frames:
+					if !ok {
+						running = false //capture stopped - try again in a moment
+					} else if time.Since(last) >= every {
+						last = time.Now()
+						fn(frame)
+					}
+				}
+			}
+			C.unsubscribe(frames)
+		}
+		select {
+		case <-C.done:
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (C *Camera) processImage(frame []byte) []byte {
+	if C.rotation != 90 && C.rotation != 180 && C.rotation != 270 {
+		return frame
+	}
+	img, _, err := image.Decode(bytes.NewReader(frame))
+	if err != nil {
+		fmt.Println("Error decoding jpeg image (image not rotated):", err)
+		return frame
+	}
+	switch C.rotation {
+	case 90:
+		img = imaging.Rotate90(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate270(img)
+	}
+	buf := new(bytes.Buffer)
+	err = jpeg.Encode(buf, img, nil)
+	if err != nil {
+		fmt.Println("Error encoding jpeg image (image not rotated):", err)
+		return frame
+	}
+	return buf.Bytes()
+}
+
+func (C *Camera) serveHttp(w http.ResponseWriter, req *http.Request) {
+	// This started as a blanket copy of the go-rpicamvid function called "HTTPHandler" (in http.go) - 5/16/2025
+	// Every viewer now gets the frames from the one shared capture instead of starting the camera again
+	frames := C.subscribe()
+	defer C.unsubscribe(frames)
+
+	mimeWriter := multipart.NewWriter(w)
+	defer mimeWriter.Close()
+	contentType := fmt.Sprintf("multipart/x-mixed-replace; boundary=%s", mimeWriter.Boundary())
+	w.Header().Set("Content-Type", contentType)
+
+	partHeader := make(textproto.MIMEHeader, 1)
+	partHeader.Add("Content-Type", "image/jpeg")
+
+	ctx := req.Context()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var frame []byte
+		select {
+		case <-ctx.Done():
+			return
+		case f, ok := <-frames:
+			if !ok {
+				return //capture stopped
+			}
+			frame = f
+		}
+
+		err := func() error {
+
+			partWriter, err := mimeWriter.CreatePart(partHeader)
+			if err != nil {
+				fmt.Printf("Failed to create multi-part section: %v\n", err)
+				return err
+			}
+
+			if _, err := partWriter.Write(frame); err != nil {
+				if errors.Is(err, syscall.EPIPE) {
+					// Client went away
+					return err
+				}
+
+				switch err.Error() {
+				case "http2: stream closed", "client disconnected":
+					// Client went away
+					return err
+				}
+				fmt.Printf("Failed to write video frame: %v\n", err)
+				return err
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			return nil
+		}()
+		if err != nil {
+			break
+		}
+	}
 }
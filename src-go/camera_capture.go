@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The shared capture for a camera.
+// Only one stream from the camera runs at a time: the capture goroutine reads each frame once
+// (rotating it as needed) and hands it to every subscriber. Subscribers only ever get the newest frame,
+// so a slow browser just skips frames instead of holding up everybody else.
+// If the camera stops working the capture keeps restarting it (waiting longer between each try),
+// and the subscribers just get the frames again once it is back.
+
+const (
+	cameraIdleStop   = 10 * time.Second //how long the camera keeps running after the last subscriber goes away
+	cameraFrameWait  = 5 * time.Second  //how long to wait for the first frame when taking a picture
+	cameraLatestFor  = 2 * time.Second  //how old the latest frame can be and still be used as a picture
+	cameraStallAfter = 30 * time.Second //no frames for this long and the stream gets replaced
+	cameraWatchEvery = 5 * time.Second
+	cameraRetryMin   = 5 * time.Second
+	cameraRetryMax   = 5 * time.Minute
+	cameraSubsBuffer = 1
+)
+
+// CameraHealth is how the camera has been doing (shown to the admins on the gate page)
+type CameraHealth struct {
+	Working      bool
+	Streaming    bool      //the capture is running right now
+	LastFrame    time.Time //last picture from the camera
+	LastError    string
+	FailingSince time.Time
+	Restarts     int //times the camera was restarted since it stopped working
+}
+
+func nextRetry(wait time.Duration) time.Duration {
+	wait *= 2
+	if wait > cameraRetryMax {
+		wait = cameraRetryMax
+	}
+	return wait
+}
+
+// Err returns the reason the camera cannot be used (nil once it has been started up)
+func (C *Camera) Err() error {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	return C.err
+}
+
+func (C *Camera) Health() CameraHealth {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	H := C.health
+	H.Streaming = C.running && !C.streamAt.IsZero()
+	return H
+}
+
+// The health functions below all need the locker held
+
+func (C *Camera) healthOK() {
+	if !C.health.Working {
+		if !C.health.FailingSince.IsZero() {
+			fmt.Println("Camera working again")
+		}
+		C.health.Working = true
+		C.health.FailingSince = time.Time{}
+		C.health.Restarts = 0
+	}
+}
+
+func (C *Camera) healthFailed(err error) {
+	if C.health.Working || C.health.FailingSince.IsZero() {
+		C.health.FailingSince = time.Now()
+	}
+	C.health.Working = false
+	C.health.LastError = err.Error()
+}
+
+// checkSource starts up the camera and closes it again, to see if it is working
+func (C *Camera) checkSource() error {
+	stream, err := C.source.Start()
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if err != nil {
+		C.healthFailed(err)
+		return err
+	}
+	stream.Close()
+	C.healthOK()
+	return nil
+}
+
+// supervise keeps trying to start the camera until it works, and then watches the capture for getting stuck
+func (C *Camera) supervise(cc CamConfig) {
+	wait := cameraRetryMin
+	for C.Err() != nil {
+		select {
+		case <-C.done:
+			return
+		case <-time.After(wait):
+		}
+		wait = nextRetry(wait)
+		C.locker.Lock()
+		C.health.Restarts++
+		C.locker.Unlock()
+		if err := C.checkSource(); err != nil {
+			fmt.Printf("Unable to start camera service: %v (trying again in %s)\n", err, wait)
+			continue
+		}
+		fmt.Println("Initialized Camera:", cc.source())
+		C.locker.Lock()
+		C.err = nil
+		C.locker.Unlock()
+	}
+	ticker := time.NewTicker(cameraWatchEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-C.done:
+			return
+		case <-ticker.C:
+			C.checkStalled()
+		}
+	}
+}
+
+// checkStalled replaces the capture if the stream has not sent a frame in a while
+// The stuck stream gets closed first: that frees up the camera for the new one (some cameras can only be
+// opened once), and wakes up the old capture so it can see that it was replaced.
+func (C *Camera) checkStalled() {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if !C.running || C.streamAt.IsZero() {
+		return
+	}
+	last := C.streamAt
+	if C.latestAt.After(last) {
+		last = C.latestAt
+	}
+	if time.Since(last) < cameraStallAfter {
+		return
+	}
+	fmt.Println("Camera stream stalled - restarting it")
+	C.healthFailed(fmt.Errorf("no frames from the camera since %s", last.Format("Jan 02, 2006 3:04:05PM MST")))
+	C.health.Restarts++
+	C.gen++
+	if C.stream != nil {
+		C.stream.Close()
+		C.stream = nil
+	}
+	C.streamAt = time.Time{}
+	go C.capture(C.gen)
+}
+
+// subscribe returns a channel which gets the frames from the camera (starting the capture if needed)
+// The channel is closed if the capture stops - call unsubscribe when done with it either way.
+func (C *Camera) subscribe() chan []byte {
+	ch := make(chan []byte, cameraSubsBuffer)
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	C.subs[ch] = true
+	if !C.running {
+		C.running = true
+		C.gen++
+		go C.capture(C.gen)
+	}
+	return ch
+}
+
+func (C *Camera) unsubscribe(ch chan []byte) {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	delete(C.subs, ch)
+	if len(C.subs) == 0 {
+		C.idleAt = time.Now()
+	}
+}
+
+// latestFrame returns the newest frame from the capture if it is recent enough (nil otherwise)
+func (C *Camera) latestFrame() []byte {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if !C.running || C.latest == nil || time.Since(C.latestAt) > cameraLatestFor {
+		return nil
+	}
+	return C.latest
+}
+
+// failing is true while the camera is not working (so there is no point waiting for a frame)
+func (C *Camera) failing() bool {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	return !C.health.Working
+}
+
+// capture runs the camera stream for as long as anybody wants frames, restarting it when it fails
+func (C *Camera) capture(gen int) {
+	wait := cameraRetryMin
+	for {
+		stream, err := C.source.Start()
+		if err == nil {
+			stream = &onceStream{FrameStream: stream} //the stream may get closed from more than one place
+			if !C.streamStarted(gen, stream) {
+				stream.Close() //replaced while starting up
+				return
+			}
+			fmt.Println("Camera capture started")
+			wait = cameraRetryMin
+			err = C.readFrames(stream, gen)
+			if err == nil {
+				return //stopped on purpose
+			}
+			stream.Close()
+		}
+		if !C.captureFailed(gen, err) {
+			return
+		}
+		fmt.Printf("Camera capture failed: %v (trying again in %s)\n", err, wait)
+		select {
+		case <-C.done:
+			C.stopCapture(gen, nil)
+			return
+		case <-time.After(wait):
+		}
+		wait = nextRetry(wait)
+	}
+}
+
+func (C *Camera) streamStarted(gen int, stream FrameStream) bool {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if gen != C.gen {
+		return false
+	}
+	C.stream = stream
+	C.streamAt = time.Now()
+	return true
+}
+
+// onceStream makes sure the stream only gets closed once
+type onceStream struct {
+	FrameStream
+	once sync.Once
+}
+
+func (S *onceStream) Close() {
+	S.once.Do(S.FrameStream.Close)
+}
+
+// captureFailed records the problem, and returns true if the capture should try again
+func (C *Camera) captureFailed(gen int, err error) bool {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if gen != C.gen {
+		return false //this capture was already replaced
+	}
+	C.healthFailed(err)
+	C.stream = nil
+	C.streamAt = time.Time{}
+	if len(C.subs) == 0 {
+		C.stopCaptureLocked(nil)
+		return false
+	}
+	C.health.Restarts++
+	return true
+}
+
+// readFrames hands out the frames from the stream until something goes wrong with it (returns nil if the capture was stopped)
+func (C *Camera) readFrames(stream FrameStream, gen int) error {
+	for {
+		select {
+		case <-C.done:
+			C.stopCapture(gen, stream)
+			return nil
+		default:
+		}
+		frame, err := stream.NextFrame()
+		if err != nil {
+			return err
+		}
+		frame = C.processImage(frame)
+		C.locker.Lock()
+		if gen != C.gen {
+			C.locker.Unlock()
+			stream.Close() //a newer capture took over while this one was stuck
+			return nil
+		}
+		C.latest = frame
+		C.latestAt = time.Now()
+		C.health.LastFrame = C.latestAt
+		C.healthOK()
+		for ch := range C.subs {
+			// Replace any frame the subscriber has not picked up yet
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- frame
+		}
+		if len(C.subs) == 0 && time.Since(C.idleAt) > cameraIdleStop {
+			fmt.Println("Camera capture stopped (idle)")
+			C.stopCaptureLocked(stream)
+			C.locker.Unlock()
+			return nil
+		}
+		C.locker.Unlock()
+	}
+}
+
+func (C *Camera) stopCapture(gen int, stream FrameStream) {
+	C.locker.Lock()
+	defer C.locker.Unlock()
+	if gen != C.gen {
+		if stream != nil {
+			stream.Close()
+		}
+		return
+	}
+	C.stopCaptureLocked(stream)
+}
+
+// stopCaptureLocked closes the stream and lets all the subscribers know that there will not be any more frames
+func (C *Camera) stopCaptureLocked(stream FrameStream) {
+	// Close the stream before anything else can start a new one (some cameras can only be opened once)
+	if stream != nil {
+		stream.Close()
+	}
+	C.running = false
+	C.stream = nil
+	C.streamAt = time.Time{}
+	C.latest = nil
+	for ch := range C.subs {
+		close(ch)
+		delete(C.subs, ch)
+	}
+	C.idleAt = time.Now()
+}
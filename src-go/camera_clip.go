@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Short video clips from around each gate event.
+// The camera keeps the last few seconds of frames in memory, and when something happens at the gate
+// the frames from before and after the event get saved into an MJPEG file next to the database.
+
+const (
+	defaultClipFPS  = 4
+	clipBoundary    = "gateclipframe"
+	clipMillisField = "X-Clip-Millis" //time of the frame from the start of the clip
+)
+
+type clipFrame struct {
+	at   time.Time
+	jpeg []byte
+}
+
+// frameBuffer is the rolling list of frames from the camera (oldest first)
+type frameBuffer struct {
+	keep   time.Duration
+	frames []clipFrame
+	locker sync.Mutex
+}
+
+func newFrameBuffer(keep time.Duration) *frameBuffer {
+	return &frameBuffer{keep: keep}
+}
+
+func (B *frameBuffer) add(frame []byte) {
+	if len(frame) == 0 {
+		return
+	}
+	now := time.Now()
+	B.locker.Lock()
+	defer B.locker.Unlock()
+	B.frames = append(B.frames, clipFrame{at: now, jpeg: frame})
+	drop := 0
+	for drop < len(B.frames) && now.Sub(B.frames[drop].at) > B.keep {
+		drop++
+	}
+	if drop > 0 {
+		B.frames = append([]clipFrame(nil), B.frames[drop:]...)
+	}
+}
+
+// between returns the frames from the start to the end time
+func (B *frameBuffer) between(start time.Time, end time.Time) []clipFrame {
+	B.locker.Lock()
+	defer B.locker.Unlock()
+	var list []clipFrame
+	for _, f := range B.frames {
+		if !f.at.Before(start) && !f.at.After(end) {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+func (cc CamConfig) clipsEnabled() bool {
+	return cc.ClipBeforeSecs > 0 || cc.ClipAfterSecs > 0
+}
+
+func (cc CamConfig) clipFPS() int {
+	if cc.ClipFPS < 1 {
+		return defaultClipFPS
+	}
+	return cc.ClipFPS
+}
+
+// startClipBuffer starts keeping frames in memory (if clips are turned on for the camera)
+// Only called from NewCamera: SaveClip reads these settings without the locker, so they never change after that.
+func (C *Camera) startClipBuffer(cc CamConfig) {
+	if !cc.clipsEnabled() {
+		return
+	}
+	C.clipBefore = time.Duration(cc.ClipBeforeSecs) * time.Second
+	C.clipAfter = time.Duration(cc.ClipAfterSecs) * time.Second
+	//Hold on to a little extra so the first frame of the clip is not cut off
+	C.buffer = newFrameBuffer(C.clipBefore + C.clipAfter + 2*time.Second)
+	go C.everyFrame(time.Second/time.Duration(cc.clipFPS()), C.buffer.add)
+}
+
+// SaveClip waits for the frames after the event, and then saves the clip for the gate log entry
+// This blocks for the "after" time of the clip - run it in a goroutine.
+func (C *Camera) SaveClip(logID int64, at time.Time) {
+	if C.buffer == nil || logID < 1 {
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	time.Sleep(time.Until(at.Add(C.clipAfter)))
+	frames := C.buffer.between(at.Add(-C.clipBefore), at.Add(C.clipAfter))
+	if len(frames) == 0 {
+		return
+	}
+	name := fmt.Sprintf("%d.mjpeg", logID)
+	if err := writeClip(filepath.Join(clipsDir(), name), frames); err != nil {
+		fmt.Println("Error saving gate clip:", err)
+		return
+	}
+	if err := DB.GateLogSetClip(logID, name); err != nil {
+		fmt.Println("Error saving gate clip:", err)
+	}
+}
+
+// clipsDir is where the clip files are kept (next to the database)
+func clipsDir() string {
+	return filepath.Join(filepath.Dir(DB.filepath), "clips")
+}
+
+// writeClip saves the frames as an MJPEG stream (each frame tagged with its time from the start)
+func writeClip(path string, frames []clipFrame) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	mw := multipart.NewWriter(file)
+	mw.SetBoundary(clipBoundary)
+	for _, f := range frames {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", "image/jpeg")
+		header.Set("Content-Length", strconv.Itoa(len(f.jpeg)))
+		header.Set(clipMillisField, strconv.FormatInt(f.at.Sub(frames[0].at).Milliseconds(), 10))
+		part, err := mw.CreatePart(header)
+		if err == nil {
+			_, err = part.Write(f.jpeg)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// serveClip plays the clip back to the browser as an MJPEG stream (at the speed it was recorded)
+func serveClip(w http.ResponseWriter, req *http.Request, name string) {
+	file, err := os.Open(filepath.Join(clipsDir(), filepath.Base(name)))
+	if err != nil {
+		http.Error(w, "Clip not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	mimeWriter := multipart.NewWriter(w)
+	defer mimeWriter.Close()
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mimeWriter.Boundary())
+	partHeader := make(textproto.MIMEHeader, 1)
+	partHeader.Add("Content-Type", "image/jpeg")
+
+	reader := multipart.NewReader(file, clipBoundary)
+	start := time.Now()
+	for req.Context().Err() == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Println("Error reading gate clip:", err)
+			return
+		}
+		frame, err := io.ReadAll(part)
+		if err != nil {
+			fmt.Println("Error reading gate clip:", err)
+			return
+		}
+		if ms, err := strconv.ParseInt(part.Header.Get(clipMillisField), 10, 64); err == nil {
+			time.Sleep(time.Until(start.Add(time.Duration(ms) * time.Millisecond)))
+		}
+		partWriter, err := mimeWriter.CreatePart(partHeader)
+		if err != nil {
+			return
+		}
+		if _, err := partWriter.Write(frame); err != nil {
+			return //client went away
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// removeClips deletes the clip files (when the gate log entries get pruned)
+func removeClips(names []string) {
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(clipsDir(), filepath.Base(name))); err != nil && !os.IsNotExist(err) {
+			fmt.Println("Error removing gate clip:", err)
+		}
+	}
+}
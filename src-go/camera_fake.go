@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cameras for testing without any camera hardware
+
+// pacedStream hands out a frame every so often (for the sources which would otherwise go as fast as possible)
+type pacedStream struct {
+	every time.Duration
+	last  time.Time
+	next  func() ([]byte, error)
+}
+
+func (S *pacedStream) NextFrame() ([]byte, error) {
+	if !S.last.IsZero() {
+		time.Sleep(time.Until(S.last.Add(S.every)))
+	}
+	S.last = time.Now()
+	return S.next()
+}
+
+func (S *pacedStream) Close() {}
+
+func frameInterval(cc CamConfig) time.Duration {
+	return time.Second / time.Duration(cc.fps())
+}
+
+// testPatternSource produces color bars which shift every second so different pictures can be told apart
+type testPatternSource struct {
+	width  int
+	height int
+	every  time.Duration
+}
+
+func newTestPatternSource(cc CamConfig) (FrameSource, error) {
+	S := testPatternSource{width: cc.Width, height: cc.Height, every: frameInterval(cc)}
+	if S.width < 1 || S.height < 1 {
+		S.width, S.height = 320, 240
+	}
+	return &S, nil
+}
+
+func (S *testPatternSource) Start() (FrameStream, error) {
+	return &pacedStream{every: S.every, next: S.frame}, nil
+}
+
+func (S *testPatternSource) frame() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, S.width, S.height))
+	bars := []color.RGBA{
+		{192, 192, 192, 255}, {192, 192, 0, 255}, {0, 192, 192, 255}, {0, 192, 0, 255},
+		{192, 0, 192, 255}, {192, 0, 0, 255}, {0, 0, 192, 255},
+	}
+	shift := int(time.Now().Unix() % int64(len(bars)))
+	for x := 0; x < S.width; x++ {
+		c := bars[(x*len(bars)/S.width+shift)%len(bars)]
+		for y := 0; y < S.height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		return nil, fmt.Errorf("encoding test pattern: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// directorySource shows the JPEG files in a folder one after another (in name order, then starting over)
+type directorySource struct {
+	dir   string
+	every time.Duration
+}
+
+func newDirectorySource(cc CamConfig) (FrameSource, error) {
+	if cc.URL == "" {
+		return nil, fmt.Errorf("directory camera needs the folder in the url")
+	}
+	return &directorySource{dir: cc.URL, every: frameInterval(cc)}, nil
+}
+
+func (S *directorySource) Start() (FrameStream, error) {
+	entries, err := os.ReadDir(S.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (ext == ".jpg" || ext == ".jpeg") {
+			files = append(files, filepath.Join(S.dir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no JPEG files in %s", S.dir)
+	}
+	sort.Strings(files)
+	// Every stream starts at the picture for the current time, so pictures taken later are different
+	i := int(time.Now().UnixNano() / int64(S.every) % int64(len(files)))
+	next := func() ([]byte, error) {
+		file := files[i]
+		i = (i + 1) % len(files)
+		return os.ReadFile(file)
+	}
+	return &pacedStream{every: S.every, next: next}, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/disintegration/imaging"
+)
+
+// Motion detection on the camera frames.
+// Each frame gets shrunk down to a small grayscale picture and compared to the one before it:
+// if enough of the pixels inside a zone changed brightness, there is something moving in that zone.
+
+const (
+	defaultMotionSensitivity = 50
+	defaultMotionFPS         = 2
+	defaultMotionCooldown    = 60
+	motionGridWidth          = 80 //pictures get shrunk to this width before comparing (faster, and less noisy)
+	motionPixelChange        = 24 //brightness change (0-255) for a pixel to count as changed
+)
+
+type MotionConfig struct {
+	Zones        []MotionZone `json:"zones"`            //parts of the picture to watch (none = the whole picture)
+	Sensitivity  int          `json:"sensitivity"`      //1-100 (default 50) - higher catches smaller movements
+	FPS          int          `json:"fps"`              //frames compared per second (default 2)
+	CooldownSecs int          `json:"cooldown_seconds"` //quiet time after a presence event or gate opening (default 60)
+	AlertSecs    int          `json:"alert_seconds"`    //let the admins know if the gate is not opened this long after presence (0 = no alert)
+}
+
+// MotionZone is a box on the picture (as seen in the web interface), in percent of the width/height
+type MotionZone struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (M MotionConfig) sensitivity() int {
+	if M.Sensitivity < 1 {
+		return defaultMotionSensitivity
+	} else if M.Sensitivity > 100 {
+		return 100
+	}
+	return M.Sensitivity
+}
+
+func (M MotionConfig) fps() int {
+	if M.FPS < 1 {
+		return defaultMotionFPS
+	}
+	return M.FPS
+}
+
+func (M MotionConfig) cooldownSecs() int {
+	if M.CooldownSecs < 1 {
+		return defaultMotionCooldown
+	}
+	return M.CooldownSecs
+}
+
+// changedFraction is how much of a zone needs to change to count as motion (10% at sensitivity 1, 0.1% at 100)
+func (M MotionConfig) changedFraction() float64 {
+	return float64(101-M.sensitivity()) / 1000
+}
+
+func (Z MotionZone) label(num int) string {
+	if Z.Name != "" {
+		return Z.Name
+	}
+	return fmt.Sprintf("zone %d", num)
+}
+
+// bounds returns the zone on a picture of the size (clipped to the picture)
+func (Z MotionZone) bounds(width int, height int) image.Rectangle {
+	r := image.Rect(Z.X*width/100, Z.Y*height/100, (Z.X+Z.Width)*width/100, (Z.Y+Z.Height)*height/100)
+	return r.Intersect(image.Rect(0, 0, width, height))
+}
+
+type motionDetector struct {
+	conf   MotionConfig
+	prev   []uint8 //brightness of the last frame (one byte per pixel)
+	width  int
+	height int
+}
+
+func newMotionDetector(conf MotionConfig) *motionDetector {
+	return &motionDetector{conf: conf}
+}
+
+// check compares the frame to the last one, and returns the zones with motion in them
+// (just "picture" when there are no zones)
+func (D *motionDetector) check(frame []byte) ([]string, error) {
+	img, err := jpeg.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	small := imaging.Grayscale(imaging.Resize(img, motionGridWidth, 0, imaging.Box))
+	width, height := small.Bounds().Dx(), small.Bounds().Dy()
+	cur := make([]uint8, width*height)
+	for i := range cur {
+		cur[i] = small.Pix[i*4] //gray, so R = G = B
+	}
+	prev := D.prev
+	D.prev = cur
+	if prev == nil || width != D.width || height != D.height {
+		D.width, D.height = width, height
+		return nil, nil //nothing to compare with yet
+	}
+	zones := D.conf.Zones
+	if len(zones) == 0 {
+		zones = []MotionZone{{Name: "picture", Width: 100, Height: 100}}
+	}
+	var moved []string
+	for i, Z := range zones {
+		r := Z.bounds(width, height)
+		if r.Empty() {
+			continue
+		}
+		changed := 0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				diff := int(cur[y*width+x]) - int(prev[y*width+x])
+				if diff > motionPixelChange || diff < -motionPixelChange {
+					changed++
+				}
+			}
+		}
+		if changed > 0 && float64(changed) >= D.conf.changedFraction()*float64(r.Dx()*r.Dy()) {
+			moved = append(moved, Z.label(i+1))
+		}
+	}
+	return moved, nil
+}
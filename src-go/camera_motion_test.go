@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"testing"
+)
+
+// motionFrame makes a gray picture with a white box on it (x, y, width, height in percent - no box if the size is 0)
+func motionFrame(t *testing.T, width int, height int, box [4]int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 100
+	}
+	for y := box[1] * height / 100; y < (box[1]+box[3])*height/100; y++ {
+		for x := box[0] * width / 100; x < (box[0]+box[2])*width/100; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMotionDetector(t *testing.T) {
+	left := MotionZone{Name: "left", X: 0, Y: 0, Width: 50, Height: 100}
+	right := MotionZone{X: 50, Y: 0, Width: 50, Height: 100} //no name - gets called "zone 2"
+	empty := MotionZone{Name: "empty", X: 10, Y: 10, Width: 0, Height: 50}
+	outside := MotionZone{Name: "outside", X: 120, Y: 0, Width: 20, Height: 100}
+	none := [4]int{}
+	tests := []struct {
+		name   string
+		conf   MotionConfig
+		second [4]int //box on the second frame (the first frame never has one)
+		want   []string
+	}{
+		{"no change", MotionConfig{Zones: []MotionZone{left, right}}, none, nil},
+		{"whole picture", MotionConfig{}, [4]int{30, 30, 40, 40}, []string{"picture"}},
+		{"inside a zone", MotionConfig{Zones: []MotionZone{left, right}}, [4]int{10, 20, 20, 40}, []string{"left"}},
+		{"unnamed zone", MotionConfig{Zones: []MotionZone{left, right}}, [4]int{70, 20, 20, 40}, []string{"zone 2"}},
+		{"both zones", MotionConfig{Zones: []MotionZone{left, right}}, [4]int{30, 20, 40, 40}, []string{"left", "zone 2"}},
+		{"outside the zones", MotionConfig{Zones: []MotionZone{left}}, [4]int{70, 20, 20, 40}, nil},
+		{"empty zone", MotionConfig{Zones: []MotionZone{empty}}, [4]int{0, 0, 100, 100}, nil},
+		{"zone off the picture", MotionConfig{Zones: []MotionZone{outside}}, [4]int{0, 0, 100, 100}, nil},
+		// A 5x5% box is 0.25% of the picture: enough at high sensitivity, not at low
+		{"small change, high sensitivity", MotionConfig{Sensitivity: 100}, [4]int{40, 40, 5, 5}, []string{"picture"}},
+		{"small change, low sensitivity", MotionConfig{Sensitivity: 1}, [4]int{40, 40, 5, 5}, nil},
+		{"small change, default sensitivity", MotionConfig{}, [4]int{40, 40, 5, 5}, nil},
+	}
+	for _, tt := range tests {
+		D := newMotionDetector(tt.conf)
+		moved, err := D.check(motionFrame(t, 320, 240, none))
+		if err != nil || moved != nil {
+			t.Errorf("%s: first frame gave %v, %v (nothing to compare with yet)", tt.name, moved, err)
+		}
+		moved, err = D.check(motionFrame(t, 320, 240, tt.second))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !reflect.DeepEqual(moved, tt.want) {
+			t.Errorf("%s: motion in %v, want %v", tt.name, moved, tt.want)
+		}
+	}
+}
+
+func TestMotionDetectorSizeChange(t *testing.T) {
+	D := newMotionDetector(MotionConfig{})
+	if _, err := D.check(motionFrame(t, 320, 240, [4]int{})); err != nil {
+		t.Fatal(err)
+	}
+	// A different picture size starts over instead of comparing against the old frame
+	moved, err := D.check(motionFrame(t, 320, 320, [4]int{0, 0, 50, 50}))
+	if err != nil || moved != nil {
+		t.Errorf("frame after a size change gave %v, %v (nothing to compare with yet)", moved, err)
+	}
+	moved, err = D.check(motionFrame(t, 320, 320, [4]int{0, 0, 50, 50}))
+	if err != nil || moved != nil {
+		t.Errorf("same frame again gave %v, %v", moved, err)
+	}
+	moved, _ = D.check(motionFrame(t, 320, 320, [4]int{}))
+	if !reflect.DeepEqual(moved, []string{"picture"}) {
+		t.Errorf("box taken away gave %v, want motion in the picture", moved)
+	}
+	if _, err := D.check([]byte("not a jpeg")); err == nil {
+		t.Error("check accepted a frame which is not a JPEG")
+	}
+}
@@ -0,0 +1,61 @@
+//go:build arm64
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cleroux/go-rpicamvid"
+)
+
+const defaultCameraSource = CameraSource_Rpicam
+
+// rpicamSource is the Raspberry Pi camera module (through the rpicam-vid tool)
+type rpicamSource struct {
+	webcam *rpicamvid.Rpicamvid
+}
+
+type rpicamStream struct {
+	stream *rpicamvid.Stream
+}
+
+func newRpicamSource(cc CamConfig) (FrameSource, error) {
+	l := log.New(os.Stdout, "", log.LstdFlags)
+	var opts []string
+	if cc.Rotation >= 180 {
+		opts = append(opts, "--rotation", fmt.Sprintf("%d", 180)) //This flag only supports 0 or 180 degrees
+	}
+	return &rpicamSource{webcam: rpicamvid.New(l, cc.Width, cc.Height, opts...)}, nil
+}
+
+func (S *rpicamSource) Start() (FrameStream, error) {
+	stream, err := S.webcam.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &rpicamStream{stream: stream}, nil
+}
+
+func (S *rpicamStream) NextFrame() ([]byte, error) {
+	// The first frames take a moment after the camera starts up
+	for tries := 0; ; tries++ {
+		fr, err := S.stream.GetFrame()
+		if errors.Is(err, rpicamvid.ErrNoFrames) && tries < 40 {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		frame := append([]byte(nil), fr.GetBytes()...)
+		fr.Close()
+		return frame, nil
+	}
+}
+
+func (S *rpicamStream) Close() {
+	S.stream.Close()
+}
@@ -0,0 +1,13 @@
+//go:build !arm64
+
+package main
+
+import (
+	"fmt"
+)
+
+const defaultCameraSource = CameraSource_TestPattern
+
+func newRpicamSource(cc CamConfig) (FrameSource, error) {
+	return nil, fmt.Errorf("the rpicamvid camera is only available on the Raspberry Pi (arm64)")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"image/color"
+	"io"
+	"testing"
+)
+
+// The ffmpeg output is just one JPEG after another - check they get split back up
+func TestReadJPEG(t *testing.T) {
+	pictures := [][]byte{
+		testJPEG(t, 32, 32, color.Gray{Y: 10}),
+		testJPEG(t, 64, 48, color.Gray{Y: 200}),
+		{0xFF, 0xD8, 0xFF, 0xFF, 0xD9}, //padding right before the end marker
+	}
+	var stream []byte
+	stream = append(stream, "junk before the first picture"...)
+	for _, p := range pictures {
+		stream = append(stream, p...)
+	}
+	stream = append(stream, pictures[0][:20]...) //cut off part way through
+
+	r := bufio.NewReaderSize(bytes.NewReader(stream), 16) //small buffer so the pictures do not fit in it
+	for i, want := range pictures {
+		got, err := readJPEG(r)
+		if err != nil {
+			t.Fatalf("picture %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("picture %d: got %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+	if _, err := readJPEG(r); err != io.EOF {
+		t.Errorf("cut off picture returned %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testJPEG makes a picture in a single color
+func testJPEG(t *testing.T, width int, height int, c color.Gray) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = c.Y
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPictureSize(t *testing.T, frame []byte) (int, int) {
+	t.Helper()
+	conf, err := jpeg.DecodeConfig(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatalf("camera frame is not a JPEG: %v", err)
+	}
+	return conf.Width, conf.Height
+}
+
+// waitFor keeps checking until the camera gets into the state (or gives up after a few seconds)
+func waitFor(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	for end := time.Now().Add(5 * time.Second); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+		if check() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestCameraDirectorySource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]bool{}
+	for i, name := range []string{"a.jpg", "b.jpeg", "c.JPG"} {
+		frame := testJPEG(t, 64, 48, color.Gray{Y: uint8(60 * i)})
+		if err := os.WriteFile(filepath.Join(dir, name), frame, 0600); err != nil {
+			t.Fatal(err)
+		}
+		files[string(frame)] = true
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a picture"), 0600)
+
+	C, err := NewCamera(CamConfig{Source: CameraSource_Directory, URL: dir, FPS: 50})
+	if err != nil {
+		t.Fatalf("NewCamera: %v", err)
+	}
+	defer C.Close()
+	if C.Health().Streaming {
+		t.Error("camera is streaming before anybody asked for frames")
+	}
+
+	frames := C.subscribe()
+	seen := map[string]bool{}
+	for len(seen) < len(files) {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				t.Fatal("capture stopped")
+			}
+			if !files[string(frame)] {
+				t.Fatal("got a frame which is not one of the pictures in the folder")
+			}
+			seen[string(frame)] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %d of the %d pictures", len(seen), len(files))
+		}
+	}
+	if !C.Health().Streaming || !C.Health().Working {
+		t.Errorf("camera health while streaming: %+v", C.Health())
+	}
+	if picture := C.TakePicture(); !files[string(picture)] {
+		t.Error("TakePicture did not return one of the pictures in the folder")
+	}
+
+	// The capture keeps going for a while after the last subscriber leaves, then stops on its own
+	C.unsubscribe(frames)
+	if !C.Health().Streaming {
+		t.Error("capture stopped as soon as the last subscriber left")
+	}
+	C.locker.Lock()
+	C.idleAt = time.Now().Add(-cameraIdleStop)
+	C.locker.Unlock()
+	waitFor(t, "the idle capture to stop", func() bool { return !C.Health().Streaming })
+	if C.latestFrame() != nil {
+		t.Error("latest frame still there after the capture stopped")
+	}
+
+	// Asking for a picture starts it up again
+	if picture := C.TakePicture(); !files[string(picture)] {
+		t.Error("TakePicture did not start the capture back up")
+	}
+}
+
+func TestCameraTestPattern(t *testing.T) {
+	C, err := NewCamera(CamConfig{Source: CameraSource_TestPattern, Width: 80, Height: 60, Rotation: 90, FPS: 50})
+	if err != nil {
+		t.Fatalf("NewCamera: %v", err)
+	}
+	defer C.Close()
+	picture := C.TakePicture()
+	if width, height := testPictureSize(t, picture); width != 60 || height != 80 {
+		t.Errorf("rotated picture is %dx%d, want 60x80", width, height)
+	}
+	// Both of these share the one capture
+	A, B := C.subscribe(), C.subscribe()
+	defer C.unsubscribe(A)
+	defer C.unsubscribe(B)
+	for _, ch := range []chan []byte{A, B} {
+		select {
+		case frame := <-ch:
+			testPictureSize(t, frame)
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscriber did not get a frame")
+		}
+	}
+}
+
+func TestCameraBrokenSource(t *testing.T) {
+	C, err := NewCamera(CamConfig{Source: CameraSource_Directory, URL: t.TempDir()})
+	if err == nil {
+		t.Fatal("NewCamera worked on a folder without any pictures")
+	}
+	defer C.Close()
+	if C.Err() == nil || C.Health().Working {
+		t.Errorf("camera health with no pictures: %+v", C.Health())
+	}
+	if picture := C.TakePicture(); len(picture) != 0 {
+		t.Error("TakePicture returned a picture from a broken camera")
+	}
+}
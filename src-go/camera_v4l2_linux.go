@@ -0,0 +1,219 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// V4L2 cameras (USB webcams and the like) which can send MJPEG frames.
+// The structs here are the 64-bit layouts from linux/videodev2.h.
+
+const (
+	v4l2BufTypeCapture = 1
+	v4l2MemoryMmap     = 1
+	v4l2FieldAny       = 0
+	v4l2PixFmtMJPEG    = 'M' | 'J'<<8 | 'P'<<16 | 'G'<<24
+	v4l2Buffers        = 4
+
+	vidiocSFmt      = 0xC0D05605
+	vidiocReqBufs   = 0xC0145608
+	vidiocQueryBuf  = 0xC0585609
+	vidiocQBuf      = 0xC058560F
+	vidiocDQBuf     = 0xC0585611
+	vidiocStreamOn  = 0x40045612
+	vidiocStreamOff = 0x40045613
+)
+
+type v4l2PixFormat struct {
+	width        uint32
+	height       uint32
+	pixelformat  uint32
+	field        uint32
+	bytesperline uint32
+	sizeimage    uint32
+	colorspace   uint32
+	priv         uint32
+	flags        uint32
+	encoding     uint32
+	quantization uint32
+	xferFunc     uint32
+}
+
+type v4l2Format struct {
+	typ uint32
+	_   uint32 //union is 8-byte aligned
+	pix v4l2PixFormat
+	_   [200 - 48]byte
+}
+
+type v4l2RequestBuffers struct {
+	count        uint32
+	typ          uint32
+	memory       uint32
+	capabilities uint32
+	flags        uint8
+	_            [3]uint8
+}
+
+type v4l2Buffer struct {
+	index     uint32
+	typ       uint32
+	bytesused uint32
+	flags     uint32
+	field     uint32
+	_         uint32
+	timestamp syscall.Timeval
+	timecode  [16]byte
+	sequence  uint32
+	memory    uint32
+	offset    uint64 //union: mmap offset in the low 32 bits
+	length    uint32
+	_         uint32
+	requestFD int32
+	_         uint32
+}
+
+type v4l2Source struct {
+	device string
+	width  int
+	height int
+}
+
+type v4l2Stream struct {
+	file    *os.File
+	buffers [][]byte
+	locker  sync.Mutex //Close can come from another goroutine while waiting for a frame
+	closed  bool
+}
+
+func newV4L2Source(cc CamConfig) (FrameSource, error) {
+	S := v4l2Source{device: cc.URL, width: cc.Width, height: cc.Height}
+	if S.device == "" {
+		S.device = "/dev/video0"
+	}
+	return &S, nil
+}
+
+func v4l2Ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+		if errno == syscall.EINTR {
+			continue
+		} else if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+func (S *v4l2Source) Start() (FrameStream, error) {
+	file, err := os.OpenFile(S.device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	stream := &v4l2Stream{file: file}
+	if err := stream.setup(S.width, S.height); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("%s: %w", S.device, err)
+	}
+	return stream, nil
+}
+
+func (S *v4l2Stream) setup(width int, height int) error {
+	fd := S.file.Fd()
+	format := v4l2Format{typ: v4l2BufTypeCapture}
+	format.pix = v4l2PixFormat{width: uint32(width), height: uint32(height), pixelformat: v4l2PixFmtMJPEG, field: v4l2FieldAny}
+	if err := v4l2Ioctl(fd, vidiocSFmt, unsafe.Pointer(&format)); err != nil {
+		return fmt.Errorf("setting the format: %w", err)
+	}
+	if format.pix.pixelformat != v4l2PixFmtMJPEG {
+		return fmt.Errorf("camera does not support MJPEG")
+	}
+	req := v4l2RequestBuffers{count: v4l2Buffers, typ: v4l2BufTypeCapture, memory: v4l2MemoryMmap}
+	if err := v4l2Ioctl(fd, vidiocReqBufs, unsafe.Pointer(&req)); err != nil {
+		return fmt.Errorf("requesting buffers: %w", err)
+	}
+	for i := uint32(0); i < req.count; i++ {
+		buf := v4l2Buffer{index: i, typ: v4l2BufTypeCapture, memory: v4l2MemoryMmap}
+		if err := v4l2Ioctl(fd, vidiocQueryBuf, unsafe.Pointer(&buf)); err != nil {
+			return fmt.Errorf("querying buffer: %w", err)
+		}
+		mem, err := syscall.Mmap(int(fd), int64(uint32(buf.offset)), int(buf.length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return fmt.Errorf("mapping buffer: %w", err)
+		}
+		S.buffers = append(S.buffers, mem)
+		if err := v4l2Ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+			return fmt.Errorf("queueing buffer: %w", err)
+		}
+	}
+	typ := uint32(v4l2BufTypeCapture)
+	if err := v4l2Ioctl(fd, vidiocStreamOn, unsafe.Pointer(&typ)); err != nil {
+		return fmt.Errorf("starting the stream: %w", err)
+	}
+	return nil
+}
+
+func (S *v4l2Stream) NextFrame() ([]byte, error) {
+	fd := S.file.Fd()
+	if err := v4l2Wait(int(fd), cameraHTTPTimeout); err != nil {
+		return nil, err
+	}
+	S.locker.Lock()
+	defer S.locker.Unlock()
+	if S.closed {
+		return nil, fmt.Errorf("camera stream closed")
+	}
+	buf := v4l2Buffer{typ: v4l2BufTypeCapture, memory: v4l2MemoryMmap}
+	if err := v4l2Ioctl(fd, vidiocDQBuf, unsafe.Pointer(&buf)); err != nil {
+		return nil, err
+	}
+	if int(buf.index) >= len(S.buffers) {
+		return nil, fmt.Errorf("invalid buffer index %d", buf.index)
+	}
+	frame := append([]byte(nil), S.buffers[buf.index][:buf.bytesused]...)
+	if err := v4l2Ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// v4l2Wait waits for the next frame to be ready (so a camera which stops sending does not block forever)
+func v4l2Wait(fd int, timeout time.Duration) error {
+	for {
+		var set syscall.FdSet
+		set.Bits[fd/64] |= 1 << (uint(fd) % 64)
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+		n, err := syscall.Select(fd+1, &set, nil, nil, &tv)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("no frame from the camera for %s", timeout)
+		}
+		return nil
+	}
+}
+
+func (S *v4l2Stream) Close() {
+	S.locker.Lock()
+	defer S.locker.Unlock()
+	if S.closed {
+		return
+	}
+	S.closed = true
+	typ := uint32(v4l2BufTypeCapture)
+	v4l2Ioctl(S.file.Fd(), vidiocStreamOff, unsafe.Pointer(&typ))
+	for _, mem := range S.buffers {
+		syscall.Munmap(mem)
+	}
+	S.buffers = nil
+	S.file.Close()
+}
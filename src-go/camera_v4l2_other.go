@@ -0,0 +1,11 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import (
+	"fmt"
+)
+
+func newV4L2Source(cc CamConfig) (FrameSource, error) {
+	return nil, fmt.Errorf("V4L2 cameras are only supported on 64-bit Linux")
+}
@@ -4,20 +4,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
 type Config struct {
-	filepath string     `json:"-"` //internal for where the file was loaded from
-	Host     string     `json:"host_port"`
-	SiteName string     `json:"site_name"`
-	DbFile   string     `json:"db_file"`
-	LogsDir  string     `json:"logs_directory"`
-	Auth     AuthConfig `json:"auth"`
-	Email    *Email     `json:"email"`
-	Keypad   *Keypad    `json:"keypad_pins"`
-	Camera   CamConfig  `json:"camera"`
-	Gate     GateConfig `json:"gate"`
-	LCD      LCDConfig  `json:"lcd_i2c"`
+	filepath  string         `json:"-"` //internal for where the file was loaded from
+	Host      string         `json:"host_port"`
+	PublicURL string         `json:"public_url"` //address of the web interface for links in notifications
+	SiteName  string         `json:"site_name"`
+	Language  string         `json:"language"` //default language for the LCDs, notifications and emails
+	DbFile    string         `json:"db_file"`
+	LogsDir   string         `json:"logs_directory"`
+	Auth      AuthConfig     `json:"auth"`
+	Email     *Email         `json:"email"`
+	GPIO      GPIOConfig     `json:"gpio"`
+	Lockout   LockoutConfig  `json:"keypad_lockout"`
+	PinEntry  PinEntryConfig `json:"pin_entry"`
+	Gates     []*GateConfig  `json:"gates"`
+	// Call-box: how long the approve link sent to a resident works
+	VisitorLinkSecs int `json:"visitor_link_seconds"`
+	// Every PIN with the last digit one higher (9 -> 0) is also a duress PIN for that code
+	DuressLastDigit bool `json:"duress_pin_last_digit"`
+	// Reworded/added messages for the catalog: language -> message key -> text
+	Messages map[string]map[string]string `json:"messages,omitempty"`
+	// Single-gate settings from older config files
+	// These get moved into the "gates" list when the config is loaded
+	Keypad *Keypad     `json:"keypad_pins,omitempty"`
+	Camera *CamConfig  `json:"camera,omitempty"`
+	Gate   *GateConfig `json:"gate,omitempty"`
+	LCD    *LCDConfig  `json:"lcd_i2c,omitempty"`
 }
 type AuthConfig struct {
 	HashKey      string `json:"hash_key"`
@@ -30,6 +45,7 @@ func DefaultConfig() Config {
 	return Config{
 		Host:     ":8080",
 		SiteName: "Gate Control",
+		Language: defaultLanguage,
 		DbFile:   "test.sqlite",
 		LogsDir:  "",
 		Auth: AuthConfig{
@@ -43,29 +59,118 @@ func DefaultConfig() Config {
 			SmtpPassword: "",
 			Sender:       "",
 		},
-		Camera: CamConfig{
-			Rotation: 0,
-			Width:    1024,
-			Height:   768,
+		Lockout: LockoutConfig{
+			MaxFailures: 5,
+			WindowSecs:  600,
+			LockSecs:    60,
+			MaxLockSecs: 3600,
 		},
-		LCD: LCDConfig{
-			Bus_num:        1,
-			Backlight_secs: 10,
-			Hex_addr:       "0x27",
+		PinEntry: PinEntryConfig{
+			MinLength:        4,
+			MaxLength:        10,
+			DigitTimeoutSecs: 30,
+			Mask:             PinMask_Stars,
 		},
+		Camera: DefaultCamConfig(),
+		LCD:    DefaultLCDConfig(),
 	}
 }
+
+func DefaultCamConfig() *CamConfig {
+	return &CamConfig{
+		Rotation: 0,
+		Width:    1024,
+		Height:   768,
+	}
+}
+
+func DefaultLCDConfig() *LCDConfig {
+	return &LCDConfig{
+		Bus_num:        1,
+		Backlight_secs: 10,
+		Hex_addr:       "0x27",
+		Size:           defaultLCDSize,
+	}
+}
+
 func LoadConfig(path string) (*Config, error) {
 	C := DefaultConfig()
 	body, err := os.ReadFile(path)
 	if err != nil {
+		C.setupGates()
 		return &C, err
 	}
 	err = json.Unmarshal(body, &C)
 	C.filepath = path //save internally for later
+	C.setupGates()
 	return &C, err
 }
 
+// setupGates converts the older single-gate settings into the gates list,
+// and makes sure every gate has a unique name
+func (C *Config) setupGates() {
+	if len(C.Gates) == 0 {
+		G := C.Gate
+		if G == nil {
+			G = &GateConfig{}
+		}
+		G.Keypad = C.Keypad
+		G.Camera = C.Camera
+		G.LCD = C.LCD
+		C.Gates = []*GateConfig{G}
+	}
+	// The single-gate fields are not used after this (and will not get saved back to the file)
+	C.Gate = nil
+	C.Keypad = nil
+	C.Camera = nil
+	C.LCD = nil
+	names := make(map[string]bool)
+	for i, G := range C.Gates {
+		if G.Name == "" || names[G.Name] {
+			G.Name = fmt.Sprintf("gate%d", i+1)
+			if i == 0 && !names["main"] {
+				G.Name = "main"
+			}
+		}
+		names[G.Name] = true
+		if G.Camera != nil {
+			def := DefaultCamConfig()
+			if G.Camera.Width < 1 || G.Camera.Height < 1 {
+				G.Camera.Width, G.Camera.Height = def.Width, def.Height
+			}
+		}
+		if G.LCD != nil {
+			def := DefaultLCDConfig()
+			if G.LCD.Hex_addr == "" && G.LCD.IsOLED() {
+				G.LCD.Hex_addr = "0x3C" //usual address for the OLED panels
+			} else if G.LCD.Hex_addr == "" {
+				G.LCD.Hex_addr = def.Hex_addr
+			}
+			if G.LCD.Backlight_secs < 1 {
+				G.LCD.Backlight_secs = def.Backlight_secs
+			}
+		}
+	}
+}
+
+// PublicBaseURL returns the address for links back to the web interface (no trailing slash)
+func (C *Config) PublicBaseURL() string {
+	if C.PublicURL != "" {
+		return strings.TrimSuffix(C.PublicURL, "/")
+	}
+	return "http://localhost" + C.Host
+}
+
+// GateByName returns the named gate (blank name = first gate)
+func (C *Config) GateByName(name string) *GateConfig {
+	for _, G := range C.Gates {
+		if name == "" || G.Name == name {
+			return G
+		}
+	}
+	return nil
+}
+
 func UpdateConfig(C *Config) {
 	if C == nil || C.filepath == "" {
 		return
@@ -12,7 +12,7 @@ import (
 // toCSV converts a LogEntry struct into a slice of strings
 // suitable for writing to a CSV file.
 
-var csvheader []string = []string{"Timestamp", "GateOpened", "OpenedBy", "OpenedHow", "AccountID"}
+var csvheader []string = []string{"Timestamp", "GateOpened", "OpenedBy", "OpenedHow", "AccountID", "Gate"}
 
 func toCSV(entry GateLog) []string {
 	log := []string{
@@ -20,12 +20,17 @@ func toCSV(entry GateLog) []string {
 		boolToString(entry.Success),
 		entry.OpenedName,
 	}
-	if entry.UsedWeb {
+	if entry.EventType == GateEvent_Card {
+		log = append(log, fmt.Sprintf("Card:%s", entry.UsedCode))
+	} else if entry.EventType != GateEvent_Open {
+		log = append(log, entry.OpenedBy())
+	} else if entry.UsedWeb {
 		log = append(log, "Website")
 	} else {
 		log = append(log, fmt.Sprintf("PIN:%s", entry.UsedCode))
 	}
 	log = append(log, fmt.Sprintf("%d", entry.AccountID))
+	log = append(log, entry.GateName)
 	return log
 }
 
@@ -92,8 +97,12 @@ func SaveCSVLog(entry GateLog, basedir string) error {
 	}
 
 	//Also write the picture to a jpg file
-	if entry.HasImage() {
-		picfile := picdir + "/" + entry.TimeOpened.Format("2006-01-02_03_04PM") + ".jpg"
+	if len(entry.GatePicture) > 0 {
+		picfile := picdir + "/" + entry.TimeOpened.Format("2006-01-02_03_04PM")
+		if len(CONFIG.Gates) > 1 {
+			picfile += "_" + entry.GateName //pictures from different gates in the same minute
+		}
+		picfile += ".jpg"
 		_ = os.WriteFile(picfile, entry.GatePicture, 0644)
 	}
 	return nil
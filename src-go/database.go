@@ -38,6 +38,9 @@ func NewDatabase(fpath string) (*Database, error) {
 	if !D.TablesExist() {
 		err = D.CreateTables()
 	}
+	if err == nil {
+		err = D.MigrateTables()
+	}
 	return &D, err
 }
 
@@ -48,8 +51,13 @@ func (D *Database) Close() {
 }
 
 func (D *Database) TablesExist() bool {
-	list, err := D.AccountsSelectAll()
-	if err == nil && len(list) > 0 {
+	// Only look at the account ID - this runs before MigrateTables adds any newer columns
+	rows, err := D.QuerySql("select account_id from account limit 1;")
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+	if rows.Next() {
 		blankdatabase = false
 		return true
 	}
@@ -78,6 +86,88 @@ func (D *Database) CreateTables() error {
 	return nil
 }
 
+// MigrateTables runs every startup to add tables which are newer than the database
+func (D *Database) MigrateTables() error {
+	err := D.CreateGateHoldTable()
+	if err != nil {
+		return err
+	}
+	err = D.CreateKeypadLockoutTables()
+	if err != nil {
+		return err
+	}
+	err = D.CreateDirectoryTable()
+	if err != nil {
+		return err
+	}
+	err = D.CreateLCDMessageTable()
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("gatelog", "event_type", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("gatelog", "gate_name", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("account_code", "gates", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("gate_hold", "gate_name", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("account_code", "code_type", "text not null default 'pin'")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("account_code", "duress_code", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("account", "language", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("gatelog", "clip_file", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.addColumn("gatelog", "picture_hash", "text not null default ''")
+	if err != nil {
+		return err
+	}
+	err = D.migrateGatePictures()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// hasColumn checks if the table has the column
+func (D *Database) hasColumn(table string, column string) (bool, error) {
+	rows, err := D.QuerySql(fmt.Sprintf("select name from pragma_table_info('%s') where name = ?;", table), column)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), nil
+}
+
+// addColumn adds a new column to an existing table (if it is not already there)
+func (D *Database) addColumn(table string, column string, def string) error {
+	exists, err := D.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+	fmt.Printf("Adding column %s to table %s\n", column, table)
+	_, err = D.ExecSql(fmt.Sprintf("alter table %s add column %s %s;", table, column, def))
+	return err
+}
+
 func (D *Database) PruneTables() {
 	//This is designed to be started as a background goroutine from main.go ONLY
 	for range time.Tick(24 * time.Hour) {
@@ -98,6 +188,19 @@ func (D *Database) PruneTables() {
 		if err != nil {
 			fmt.Printf("Got error pruning Accounts before %v: %v", ya, err)
 		}
+		err = D.PruneGateHolds(ya)
+		if err != nil {
+			fmt.Printf("Got error pruning GateHolds before %v: %v", ya, err)
+		}
+		err = D.PruneLCDMessages(ya)
+		if err != nil {
+			fmt.Printf("Got error pruning LCDMessages before %v: %v", ya, err)
+		}
+		da := time.Now().AddDate(0, 0, -1) //day ago - only the recent failures matter for lockouts
+		err = D.PruneKeypadFailures(da)
+		if err != nil {
+			fmt.Printf("Got error pruning KeypadFailures before %v: %v", da, err)
+		}
 	}
 }
 
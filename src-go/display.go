@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Display is the panel behind the LCD.
+// The LCD goroutine lays out the text, and the panel just puts each line (and icon) on the screen.
+type Display interface {
+	Clear() error
+	WriteLine(row int, text string) error //text already fits the width (blank clears the line)
+	SetIcon(icon string) error            //blank = no icon (ignored by panels which cannot show one)
+	SetBacklight(on bool) error           //OLED panels dim instead
+	Close() error
+}
+
+// lcdBus is the I2C connection to the display (a real device, or the fake one)
+type lcdBus interface {
+	WriteBytes(buf []byte) (int, error)
+	Close() error
+}
+
+// Types of display
+const (
+	LCDType_HD44780 = "hd44780" //character LCD on a PCF8574 backpack (default)
+	LCDType_SSD1306 = "ssd1306" //128x64 OLED
+	LCDType_SH1106  = "sh1106"  //128x64 OLED (132 column memory)
+)
+
+// Icons for the gate states (only shown on OLED panels)
+const (
+	LCDIcon_Locked  = "locked"
+	LCDIcon_Welcome = "welcome"
+	LCDIcon_Error   = "error"
+)
+
+// newDisplay starts up the panel on the bus (the text size for OLED panels is fixed)
+func (L *LCDConfig) newDisplay(bus lcdBus) (Display, error) {
+	switch L.Type {
+	case "", LCDType_HD44780:
+		return newHD44780(bus, L.cols, L.rows)
+	case LCDType_SSD1306, LCDType_SH1106:
+		L.cols, L.rows = oledCols, oledRows
+		return newSSD1306(bus, L.Type == LCDType_SH1106)
+	}
+	return nil, fmt.Errorf("unknown display type: %s", L.Type)
+}
+
+// IsOLED reports whether the display is one of the OLED panels
+func (L *LCDConfig) IsOLED() bool {
+	return L != nil && (L.Type == LCDType_SSD1306 || L.Type == LCDType_SH1106)
+}
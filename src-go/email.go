@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"net/mail"
 
@@ -23,6 +24,11 @@ type Email struct {
 }
 
 func (E *Email) SendEmail(to string, subject string, body string, initialEmail bool) error {
+	return E.SendEmailPicture(to, subject, body, initialEmail, nil)
+}
+
+// SendEmailPicture is the same as SendEmail, but with a JPEG picture attached (if there is one)
+func (E *Email) SendEmailPicture(to string, subject string, body string, initialEmail bool, picture []byte) error {
 	if E.SmtpHost == "" || E.SmtpPort == 0 || E.SmtpUsername == "" {
 		fmt.Println("Email system not configured")
 		return nil //do nothing - email system not setup
@@ -39,6 +45,9 @@ func (E *Email) SendEmail(to string, subject string, body string, initialEmail b
 	message.SetHeader("Subject", subject)
 	// Set email body
 	message.SetBody("text/plain", body)
+	if len(picture) > 0 {
+		message.AttachReader("gate.jpg", bytes.NewReader(picture))
+	}
 
 	// Set up the SMTP dialer
 	dialer := gomail.NewDialer(E.SmtpHost, E.SmtpPort, E.SmtpUsername, E.SmtpPassword)
@@ -12,6 +12,9 @@ func LoadAccountCodeFromForm(r *http.Request) (AccountCode, error) {
 	r.ParseForm()
 	acodeid := r.Form.Get("acodeid")
 	code := r.Form.Get("code")
+	codetype := r.Form.Get("codetype")
+	cardnum := r.Form.Get("cardnum")
+	duress := r.Form.Get("duress")
 	codelength := parseFormInt(r.Form.Get("codelength"))
 	label := r.Form.Get("label")
 	is_active := r.Form.Get("isactive") == formChecked
@@ -46,12 +49,41 @@ func LoadAccountCodeFromForm(r *http.Request) (AccountCode, error) {
 		AC = list[0]
 	}
 	// Validate the inputs
+	if codetype == CodeType_Card && AC.Code == "" {
+		//New card/fob - the number comes from the card itself
+		if !validateCardFormat(cardnum) {
+			return AC, fmt.Errorf("card number must be a 26 or 34 bit number")
+		}
+		AC.CodeType = CodeType_Card
+		code = cardnum
+	}
 	if code == "" && AC.Code == "" && codelength == 0 {
 		return AC, fmt.Errorf("missing PIN Code")
 	}
-	if code != "" && !validatePinCodeFormat(code) {
+	if code != "" && !AC.IsCard() && !validatePinCodeFormat(code) {
 		return AC, fmt.Errorf("PIN code must be 4 or more numbers")
 	}
+	if code != "" && !AC.IsCard() && !CONFIG.PinEntry.validLength(code) {
+		return AC, CONFIG.PinEntry.lengthError("PIN code")
+	}
+	if codelength != 0 && (codelength < CONFIG.PinEntry.minLength() || codelength > CONFIG.PinEntry.maxLength()) {
+		return AC, CONFIG.PinEntry.lengthError("PIN code")
+	}
+
+	if duress != "" && duress != AC.DuressCode {
+		if AC.IsCard() {
+			return AC, fmt.Errorf("cards cannot have a duress PIN")
+		}
+		if !validatePinCodeFormat(duress) {
+			return AC, fmt.Errorf("duress PIN must be 4 or more numbers")
+		}
+		if !CONFIG.PinEntry.validLength(duress) {
+			return AC, CONFIG.PinEntry.lengthError("duress PIN")
+		}
+		if duress == AC.Code || DB.AccountCodeInUse(duress) || DB.AccountCodeIsLastDigitDuress(duress) {
+			return AC, fmt.Errorf("duress PIN is already in use - pick another one")
+		}
+	}
 
 	if label == "" && AC.Label == "" {
 		return AC, fmt.Errorf("missing Description")
@@ -73,6 +105,7 @@ func LoadAccountCodeFromForm(r *http.Request) (AccountCode, error) {
 	if label != "" {
 		AC.Label = label
 	}
+	AC.DuressCode = duress
 	AC.IsActive = is_active
 	AC.IsUtility = is_utility
 	AC.IsDelivery = is_delivery
@@ -100,6 +133,14 @@ func LoadAccountCodeFromForm(r *http.Request) (AccountCode, error) {
 	if day_Sa {
 		AC.ValidDays = append(AC.ValidDays, "sa")
 	}
+	if len(CONFIG.Gates) > 1 {
+		// Gate choices are only on the form when there is more than one gate
+		gates, err := parseFormGates(r.Form["gates"])
+		if err != nil {
+			return AC, err
+		}
+		AC.Gates = gates
+	}
 	if date_start != nil {
 		AC.DateStart = *date_start
 	} else {
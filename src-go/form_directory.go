@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func LoadDirectoryFromForm(r *http.Request) (DirectoryEntry, error) {
+	// Parse the form
+	r.ParseForm()
+	entryid := r.Form.Get("entryid")
+	number := r.Form.Get("number")
+	accountid := parseFormInt(r.Form.Get("accountid"))
+	label := r.Form.Get("label")
+	is_active := r.Form.Get("isactive") == formChecked
+
+	E := DirectoryEntry{}
+	if entryid != "" {
+		//Loading a pre-existing entry
+		num, err := strconv.ParseInt(entryid, 10, 64)
+		if err != nil {
+			return E, err
+		}
+		list, err := DB.DirectorySelectAll(num)
+		if err != nil || len(list) != 1 {
+			return E, fmt.Errorf("invalid directory entry ID")
+		}
+		E = list[0]
+	}
+	// Validate the inputs
+	if number == "" {
+		return E, fmt.Errorf("missing Unit Number")
+	}
+	for _, v := range number {
+		if v < '0' || v > '9' {
+			return E, fmt.Errorf("unit number must be numbers only (typed on the keypad)")
+		}
+	}
+	if other, _ := DB.DirectoryMatch(number, false); other != nil && other.EntryID != E.EntryID {
+		return E, fmt.Errorf("unit number %s is already in the directory", number)
+	}
+	if label == "" {
+		return E, fmt.Errorf("missing Label")
+	}
+	acct, err := DB.AccountFromID(int32(accountid))
+	if err != nil || acct == nil || accountid < 1 {
+		return E, fmt.Errorf("invalid resident account")
+	}
+
+	// Populate the fields
+	E.Number = number
+	E.AccountID = acct.AccountID
+	E.Label = label
+	E.IsActive = is_active
+	return E, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func LoadGateHoldFromForm(r *http.Request) (GateHold, error) {
+	// Parse the form
+	r.ParseForm()
+	holdid := r.Form.Get("holdid")
+	label := r.Form.Get("label")
+	gate := r.Form.Get("gate")
+	is_active := r.Form.Get("isactive") == formChecked
+	date_start := parseFormDate(r.Form.Get("dstart"))
+	date_end := parseFormDate(r.Form.Get("dend"))
+	time_start := parseFormTime(r.Form.Get("tstart"))
+	time_end := parseFormTime(r.Form.Get("tend"))
+
+	H := GateHold{}
+	if holdid != "" {
+		//Loading a pre-existing schedule
+		num, err := strconv.ParseInt(holdid, 10, 64)
+		if err != nil {
+			return H, err
+		}
+		list, err := DB.GateHoldSelectAll(num, false)
+		if err != nil || len(list) != 1 || list[0].IsManual {
+			return H, fmt.Errorf("invalid hold schedule ID")
+		}
+		H = list[0]
+	}
+	// Validate the inputs
+	if label == "" && H.Label == "" {
+		return H, fmt.Errorf("missing Description")
+	}
+	if time_start == nil || time_end == nil {
+		return H, fmt.Errorf("missing start/end time")
+	}
+	if gate != "" && CONFIG.GateByName(gate) == nil {
+		return H, fmt.Errorf("unknown gate: %s", gate)
+	}
+
+	// Populate the fields
+	if label != "" {
+		H.Label = label
+	}
+	H.GateName = gate
+	H.IsActive = is_active
+	H.ValidDays = []string{} //Reset and reload
+	for _, day := range []struct{ field, abbr string }{
+		{"d_sunday", "su"},
+		{"d_monday", "mo"},
+		{"d_tuesday", "tu"},
+		{"d_wednesday", "we"},
+		{"d_thursday", "th"},
+		{"d_friday", "fr"},
+		{"d_saturday", "sa"},
+	} {
+		if r.Form.Get(day.field) == formChecked {
+			H.ValidDays = append(H.ValidDays, day.abbr)
+		}
+	}
+	H.DateStart = time.Time{}
+	if date_start != nil {
+		H.DateStart = *date_start
+	}
+	H.DateEnd = time.Time{}
+	if date_end != nil {
+		H.DateEnd = *date_end
+	}
+	H.TimeStart = *time_start
+	H.TimeEnd = *time_end
+	return H, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func LoadLCDMessageFromForm(r *http.Request) (LCDMessage, error) {
+	// Parse the form
+	r.ParseForm()
+	messageid := r.Form.Get("messageid")
+	label := r.Form.Get("label")
+	text := r.Form.Get("message")
+	gate := r.Form.Get("gate")
+	is_active := r.Form.Get("isactive") == formChecked
+	backlight := r.Form.Get("backlight") == formChecked
+	date_start := parseFormDate(r.Form.Get("dstart"))
+	date_end := parseFormDate(r.Form.Get("dend"))
+	time_start := parseFormTime(r.Form.Get("tstart"))
+	time_end := parseFormTime(r.Form.Get("tend"))
+
+	M := LCDMessage{}
+	if messageid != "" {
+		//Loading a pre-existing message
+		num, err := strconv.ParseInt(messageid, 10, 64)
+		if err != nil {
+			return M, err
+		}
+		list, err := DB.LCDMessageSelectAll(num, false)
+		if err != nil || len(list) != 1 {
+			return M, fmt.Errorf("invalid LCD message ID")
+		}
+		M = list[0]
+	}
+	// Validate the inputs
+	if label == "" && M.Label == "" {
+		return M, fmt.Errorf("missing Description")
+	}
+	if text == "" {
+		return M, fmt.Errorf("missing Message")
+	}
+	if (time_start == nil) != (time_end == nil) {
+		return M, fmt.Errorf("set both the start and end times (or neither for all day)")
+	}
+	if gate != "" && CONFIG.GateByName(gate) == nil {
+		return M, fmt.Errorf("unknown gate: %s", gate)
+	}
+
+	// Populate the fields
+	if label != "" {
+		M.Label = label
+	}
+	M.Text = text
+	M.GateName = gate
+	M.IsActive = is_active
+	M.BacklightOn = backlight
+	M.ValidDays = []string{} //Reset and reload
+	for _, day := range []struct{ field, abbr string }{
+		{"d_sunday", "su"},
+		{"d_monday", "mo"},
+		{"d_tuesday", "tu"},
+		{"d_wednesday", "we"},
+		{"d_thursday", "th"},
+		{"d_friday", "fr"},
+		{"d_saturday", "sa"},
+	} {
+		if r.Form.Get(day.field) == formChecked {
+			M.ValidDays = append(M.ValidDays, day.abbr)
+		}
+	}
+	M.DateStart = time.Time{}
+	if date_start != nil {
+		M.DateStart = *date_start
+	}
+	M.DateEnd = time.Time{}
+	if date_end != nil {
+		M.DateEnd = *date_end
+	}
+	M.TimeStart, M.TimeEnd = time.Time{}, time.Time{}
+	if time_start != nil {
+		M.TimeStart, M.TimeEnd = *time_start, *time_end
+	}
+	return M, nil
+}
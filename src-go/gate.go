@@ -1,15 +1,200 @@
-//go:build !arm64
-
 package main
 
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GateConfig is a single gate: the relay which opens it and the optional
+// keypad, LCD and camera mounted beside it.
 type GateConfig struct {
-	GpioPin int `json:"gpio_num"`
+	Name    string         `json:"name"`
+	GpioPin uint32         `json:"gpio_num"`
+	Invert  bool           `json:"invert_drive"`
+	Keypad  *Keypad        `json:"keypad_pins,omitempty"`
+	LCD     *LCDConfig     `json:"lcd_i2c,omitempty"`
+	Camera  *CamConfig     `json:"camera,omitempty"`
+	Sensor  *GateSensor    `json:"sensor,omitempty"`
+	Wiegand *WiegandConfig `json:"wiegand,omitempty"`
+	// Internal variables
+	cam       *Camera       `json:"-"`
+	status    gateStatus    `json:"-"` //position from the sensor
+	locker    sync.Mutex    `json:"-"`
+	holdlabel string        `json:"-"` //label of the hold keeping the gate open (blank = not held)
+	presence  presenceState `json:"-"`
 }
 
-func (gc *GateConfig) OpenGate() {
+// Setup gets the relay, camera, LCD, keypad, and card reader for the gate ready to use
+func (gc *GateConfig) Setup() error {
+	err := gc.SetupGate()
+	if err != nil {
+		return err
+	}
+	err = gc.StartSensor()
+	if err != nil {
+		return fmt.Errorf("Gate sensor: %w", err)
+	}
+	if gc.Camera != nil {
+		gc.cam, err = NewCamera(*gc.Camera)
+		if err != nil {
+			fmt.Println(gc.Name, "camera:", err) //keep going without pictures (it keeps trying in the background)
+		}
+		gc.startPresence()
+	}
+	if gc.LCD != nil {
+		err = gc.LCD.Setup(func() (string, bool) { return IdleScreen(gc.Name) })
+		if err != nil {
+			return fmt.Errorf("I2C LCD: %w", err)
+		}
+	}
+	if gc.Keypad != nil {
+		gc.Keypad.gate = gc
+		err = gc.Keypad.StartWatching()
+		if err != nil {
+			return fmt.Errorf("Keypad: %w", err)
+		}
+	}
+	if gc.Wiegand != nil {
+		gc.Wiegand.gate = gc
+		err = gc.Wiegand.Start()
+		if err != nil {
+			return fmt.Errorf("Wiegand reader: %w", err)
+		}
+	}
+	return nil
+}
 
+func (gc *GateConfig) Close() {
+	gc.stopSensor()
+	gc.Keypad.Close()
+	gc.Wiegand.Stop()
+	gc.LCD.Close()
+	if gc.cam != nil {
+		gc.cam.Close()
+	}
+}
+
+// TakePicture returns a picture from the gate camera (empty if there is no camera)
+func (gc *GateConfig) TakePicture() []byte {
+	if gc == nil || gc.cam == nil {
+		return []byte{}
+	}
+	return gc.cam.TakePicture()
+}
+
+// SaveClip stores the video from around the event with the gate log entry (if the camera keeps clips)
+func (gc *GateConfig) SaveClip(gl *GateLog) {
+	if gc == nil || gc.cam == nil || gl == nil {
+		return
+	}
+	go gc.cam.SaveClip(gl.LogID, gl.TimeOpened)
+}
+
+func (gc *GateConfig) HasCamera() bool {
+	return gc.cam != nil
+}
+
+// CameraHealth is how the gate camera has been doing (for the admins)
+func (gc *GateConfig) CameraHealth() CameraHealth {
+	if gc.cam == nil {
+		return CameraHealth{}
+	}
+	return gc.cam.Health()
+}
+
+// Display shows a gate event on the LCD for the number of seconds
+// Anything the keypad was showing comes back afterwards.
+func (gc *GateConfig) Display(text string, seconds int) {
+	if gc == nil {
+		return
+	}
+	gc.LCD.Show(text, LCDPriority_Status, seconds)
+}
+
+// DisplayIcon is Display with an icon (on displays which can show one)
+func (gc *GateConfig) DisplayIcon(icon string, text string, seconds int) {
+	if gc == nil {
+		return
+	}
+	gc.LCD.ShowIcon(icon, text, LCDPriority_Status, seconds)
 }
 
 func (gc *GateConfig) SetupGate() error {
+	if gc == nil || gc.GpioPin < 1 {
+		fmt.Println("No Gate configured!!")
+		return fmt.Errorf("No Gate Configured")
+	}
+	//Now set the Pin to the right settings
+	GPIO.SetOutput(gc.GpioPin) //Set as output device
+	gc.SetDrive(false)
+	return nil
+}
+
+func (gc *GateConfig) OpenGate() (err error) {
+	if gc == nil || gc.GpioPin < 1 {
+		fmt.Println("No Gate configured!!")
+		return fmt.Errorf("No Gate Configured")
+	}
+	if !gc.locker.TryLock() {
+		// Already locked - no need to trigger this again
+		// Makes sure that multiple gate open requests get collapsed to a single trigger
+		return nil
+	}
+	defer gc.locker.Unlock()
+	if gc.holdlabel != "" {
+		return nil //already held open
+	}
+	// Turn it on for 1 second, then turn it back off again
+	err = gc.SetDrive(true)
+	if err != nil {
+		return err
+	}
+	// The gate may start moving before the pulse is finished
+	gc.commandedOpen()
+	gc.presenceOpened()
+	time.Sleep(time.Second) //wait one second
+	err = gc.SetDrive(false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
+
+func (gc *GateConfig) SetDrive(on bool) error {
+	if gc.Invert {
+		on = !on //reverse the order
+	}
+	if on {
+		return GPIO.SetOutputDriveHigh(gc.GpioPin)
+	} else {
+		return GPIO.SetOutputDriveLow(gc.GpioPin)
+	}
+}
+
+// SetHold keeps the relay turned on until called again with a blank label
+func (gc *GateConfig) SetHold(label string) error {
+	if gc == nil || gc.GpioPin < 1 {
+		return fmt.Errorf("No Gate Configured")
+	}
+	gc.locker.Lock() //wait for any pulse in progress to finish
+	defer gc.locker.Unlock()
+	err := gc.SetDrive(label != "")
+	if err == nil {
+		if label != "" && gc.holdlabel == "" {
+			gc.commandedOpen()
+			gc.presenceOpened()
+		}
+		gc.holdlabel = label
+	}
+	return err
+}
+
+func (gc *GateConfig) HoldLabel() string {
+	if gc == nil {
+		return ""
+	}
+	gc.locker.Lock()
+	defer gc.locker.Unlock()
+	return gc.holdlabel
+}
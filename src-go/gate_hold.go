@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// How often the hold-open schedules get checked
+const holdCheckInterval = 30 * time.Second
+
+var holdRecheck = make(chan bool, 1)
+
+// RecheckHolds asks the scheduler to look at the holds again right away (after changes from the web)
+func RecheckHolds() {
+	select {
+	case holdRecheck <- true:
+	default:
+		//already pending
+	}
+}
+
+func RunHoldScheduler() {
+	//This is designed to be started as a background goroutine from main.go ONLY
+	// Runs right away on startup so any holds get re-applied after a restart
+	ticker := time.NewTicker(holdCheckInterval)
+	defer ticker.Stop()
+	for {
+		applyGateHolds()
+		select {
+		case <-ticker.C:
+		case <-holdRecheck:
+		}
+	}
+}
+
+func applyGateHolds() {
+	holds, err := DB.GateHoldSelectAll(0, true)
+	if err != nil {
+		fmt.Println("Error reading GateHolds:", err)
+		return
+	}
+	for _, G := range CONFIG.Gates {
+		applyGateHold(G, holds)
+	}
+}
+
+// applyGateHold holds open (or releases) a single gate based on the active holds
+func applyGateHold(G *GateConfig, holds []GateHold) {
+	var active *GateHold
+	for i := range holds {
+		if holds[i].AppliesTo(G.Name) && holds[i].OpenNow() {
+			active = &holds[i]
+			break
+		}
+	}
+	current := G.HoldLabel()
+	switch {
+	case active != nil && current == "":
+		fmt.Println("Holding gate open:", G.Name, active.Label)
+		if err := G.SetHold(active.Label); err != nil {
+			fmt.Println("Error holding gate open:", err)
+			return
+		}
+		logGateHold(G, active, GateEvent_HoldStart)
+		G.Display(SiteMessage(Msg_GateHeld), 5)
+	case active == nil && current != "":
+		fmt.Println("Releasing gate hold:", G.Name, current)
+		if err := G.SetHold(""); err != nil {
+			fmt.Println("Error releasing gate hold:", err)
+			return
+		}
+		logGateHold(G, &GateHold{Label: current}, GateEvent_HoldEnd)
+		G.Display(SiteMessage(Msg_GateHoldEnded), 5)
+	case active != nil && current != active.Label:
+		// Switched over to a different hold - gate stays open
+		G.SetHold(active.Label)
+	}
+}
+
+func logGateHold(G *GateConfig, H *GateHold, event string) {
+	gl := GateLog{
+		AccountID:  H.AccountID,
+		OpenedName: H.Label,
+		EventType:  event,
+		GateName:   G.Name,
+		TimeOpened: time.Now(),
+		Success:    true,
+	}
+	if event == GateEvent_HoldStart {
+		gl.GatePicture = G.TakePicture()
+	}
+	_, err := DB.GateLogInsert(&gl)
+	if err != nil {
+		fmt.Println("Error inserting GateLog:", err)
+	} else if event == GateEvent_HoldStart {
+		G.SaveClip(&gl)
+	}
+	go SaveCSVLog(gl, CONFIG.LogsDir)
+}
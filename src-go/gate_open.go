@@ -1,34 +1,80 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
-func CheckPINAndOpen(pin string) error {
+func CheckPINAndOpen(gate *GateConfig, pin string) error {
+	if KeypadLocked(gate) {
+		return errors.New(SiteMessage(Msg_KeypadLocked)) //PIN is not even checked
+	}
 	ac, err := DB.AccountCodeMatch(pin)
 	if err != nil {
 		return err
 	}
+	if ac != nil && (ac.IsCard() || !ac.AllowsGate(gate.Name)) {
+		ac = nil //card number or not allowed at this gate - same as an invalid PIN
+	}
+	if ac == nil {
+		// Duress PINs act just like the real PIN - the admins get alerted from OpenGateAndNotify
+		ac, err = DB.AccountCodeDuressMatch(pin)
+		if err != nil {
+			return err
+		}
+		if ac != nil && !ac.AllowsGate(gate.Name) {
+			ac.IsActive = false //gate stays closed, but the admins still get the alert
+		}
+	}
 	// if ac==nil, invalid PIN
-	err = OpenGateAndNotify(nil, ac)
+	err = OpenGateAndNotify(gate, nil, ac)
 	if ac == nil || err != nil {
-		return fmt.Errorf("Invalid PIN")
+		if keypadFailed(gate) {
+			return errors.New(SiteMessage(Msg_KeypadLocked))
+		}
+		return errors.New(SiteMessage(Msg_InvalidPIN))
+	}
+	return nil
+}
+
+// CheckCardAndOpen opens the gate for a card/fob from the Wiegand reader
+func CheckCardAndOpen(gate *GateConfig, card string) error {
+	ac, err := DB.AccountCodeMatch(card)
+	if err != nil {
+		return err
+	}
+	if ac == nil || !ac.IsCard() || !ac.AllowsGate(gate.Name) {
+		//Unknown card (never valid) - still logged with the number so it can be registered
+		ac = &AccountCode{Code: card, CodeType: CodeType_Card}
+	}
+	err = OpenGateAndNotify(gate, nil, ac)
+	if err != nil {
+		gate.DisplayIcon(LCDIcon_Error, SiteMessage(Msg_InvalidCard), 2)
+		return errors.New(SiteMessage(Msg_InvalidCard))
 	}
 	return nil
 }
 
-func OpenGateAndNotify(acct *Account, code *AccountCode) error {
+func OpenGateAndNotify(gate *GateConfig, acct *Account, code *AccountCode) error {
 	// Now determine who to notify and send out notices
-	var emails []string
-	msg := "%s is entering the neighborhood"
-	subject := fmt.Sprintf("%s Gate Notification", CONFIG.SiteName)
+	var contacts []Contact
+	welcome := siteLanguage() //shown in the language of the account holder
 	var gl GateLog
 	gl.TimeOpened = time.Now()
 	gl.OpenedName = "unknown"
+	gl.GateName = gate.Name
+	if code != nil && code.IsCard() {
+		gl.EventType = GateEvent_Card
+		gl.UsedCode = code.Code
+	} else if code != nil && code.CodeType == CodeType_Visitor {
+		gl.EventType = GateEvent_Visitor
+	} else if code != nil && code.Duress {
+		gl.EventType = GateEvent_Duress
+		gl.AccountID = code.AccountID
+		gl.OpenedName = code.Label
+	}
 	if code != nil && code.IsValid() {
-		msg = fmt.Sprintf(msg, code.Label)
-		var contacts []Contact
 		var err error
 		//Gate PIN used - notify everybody associated
 		if !code.IsUtility && !code.IsDelivery {
@@ -41,10 +87,7 @@ func OpenGateAndNotify(acct *Account, code *AccountCode) error {
 		if err != nil {
 			fmt.Println("Error reading Contacts:", err)
 		}
-		for _, c := range contacts {
-			// Assemble the list of people to contacts
-			emails = append(emails, c.ContactEmail())
-		}
+		welcome = DB.AccountLanguage(code.AccountID)
 		// Assemble the gate log
 		gl.AccountID = code.AccountID
 		gl.OpenedName = code.Label
@@ -53,7 +96,6 @@ func OpenGateAndNotify(acct *Account, code *AccountCode) error {
 		gl.Success = true
 
 	} else if acct != nil {
-		msg = fmt.Sprintf(msg, fmt.Sprintf("%s %s has opened the gate.", acct.FirstName, acct.LastName))
 		//Web Portal used - no need to notify anyone?
 		gl.AccountID = acct.AccountID
 		gl.OpenedName = fmt.Sprintf("%s, %s", acct.LastName, acct.FirstName)
@@ -65,29 +107,62 @@ func OpenGateAndNotify(acct *Account, code *AccountCode) error {
 		gl.UsedWeb = false //if web is used, never get a failure/invalid
 	}
 	// Snap a picture from the gate
-	gl.GatePicture = CAM.TakePicture()
+	gl.GatePicture = gate.TakePicture()
 
 	// Open the Gate
 	if gl.Success {
-		fmt.Println("Opening Gate!!")
-		CONFIG.Gate.OpenGate()
-		CONFIG.Keypad.DisplayOnLCD("Welcome!", 2)
+		fmt.Println("Opening Gate!!", gate.Name)
+		gate.OpenGate()
+		gate.DisplayIcon(LCDIcon_Welcome, Message(welcome, Msg_Welcome), 2)
 	}
 
 	// Record the gate log
 	_, err := DB.GateLogInsert(&gl)
 	if err != nil {
 		fmt.Println("Error inserting GateLog:", err)
+	} else {
+		gate.SaveClip(&gl)
 	}
 	go SaveCSVLog(gl, CONFIG.LogsDir)
+	if gl.EventType == GateEvent_Duress {
+		// Silent alarm - nothing different shows at the gate
+		go NotifyAdmins(gl.GatePicture, Msg_DuressSubject, Msg_DuressAlert, code.Label, gate.Name, gl.Success)
+	}
 
 	if !gl.Success {
 		return fmt.Errorf("unknown gate open request - denied")
 	}
-	// Now send all the notification emails for successes
-	msg = fmt.Sprintf("[%s] ", time.Now().Format("Jan _2: 03:04 MST")) + msg
-	for _, to := range emails {
-		CONFIG.Email.SendEmail(to, subject, msg, false)
+	// Now send all the notification emails for successes (in the language for each contact)
+	stamp := fmt.Sprintf("[%s] ", time.Now().Format("Jan _2: 03:04 MST"))
+	for _, c := range contacts {
+		lang := c.MessageLanguage()
+		msg := Message(lang, Msg_GateEntering, code.Label)
+		if len(CONFIG.Gates) > 1 {
+			msg += Message(lang, Msg_GateSuffix, gate.Name)
+		}
+		CONFIG.Email.SendEmail(c.ContactEmail(), Message(lang, Msg_GateSubject, CONFIG.SiteName), stamp+msg, false)
 	}
 	return nil
 }
+
+// NotifyAdmins sends an alert to all the contacts for the admin accounts (with the gate picture attached)
+// The subject gets the site name, and the alert message gets the args (true/false args become yes/no).
+func NotifyAdmins(picture []byte, subjectKey string, alertKey string, args ...interface{}) {
+	contacts, err := DB.ContactsForAdminNotify()
+	if err != nil {
+		fmt.Println("Error reading admin Contacts:", err)
+		return
+	}
+	stamp := fmt.Sprintf("[%s] ", time.Now().Format("Jan _2: 03:04 MST"))
+	for _, c := range contacts {
+		lang := c.MessageLanguage()
+		largs := make([]interface{}, len(args))
+		for i, arg := range args {
+			if b, ok := arg.(bool); ok {
+				arg = yesNo(lang, b)
+			}
+			largs[i] = arg
+		}
+		CONFIG.Email.SendEmailPicture(c.ContactEmail(), Message(lang, subjectKey, CONFIG.SiteName), stamp+Message(lang, alertKey, largs...), false, picture)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// presenceState is the motion tracking for a gate camera with motion detection turned on
+type presenceState struct {
+	lastEvent time.Time //last presence event logged
+	lastOpen  time.Time //last time the gate was told to open
+	locker    sync.Mutex
+}
+
+// startPresence watches the gate camera for motion (if turned on)
+func (gc *GateConfig) startPresence() {
+	if gc.cam == nil || gc.Camera == nil || gc.Camera.Motion == nil {
+		return
+	}
+	M := *gc.Camera.Motion
+	D := newMotionDetector(M)
+	go gc.cam.everyFrame(time.Second/time.Duration(M.fps()), func(frame []byte) {
+		zones, err := D.check(frame)
+		if err != nil {
+			fmt.Println("Error checking camera frame for motion:", err)
+		} else if len(zones) > 0 {
+			gc.presenceDetected(M, frame, zones)
+		}
+	})
+}
+
+// presenceOpened records that the gate was opened (so motion from the traffic going through does not count)
+func (gc *GateConfig) presenceOpened() {
+	gc.presence.locker.Lock()
+	defer gc.presence.locker.Unlock()
+	gc.presence.lastOpen = time.Now()
+}
+
+// presenceDetected logs the presence event (unless there was one recently, or the gate was just opened)
+func (gc *GateConfig) presenceDetected(M MotionConfig, frame []byte, zones []string) {
+	if gc.HoldLabel() != "" {
+		return //held open - just traffic going through
+	}
+	now := time.Now()
+	quiet := time.Duration(M.cooldownSecs()) * time.Second
+	gc.presence.locker.Lock()
+	recent := now.Sub(gc.presence.lastEvent) < quiet || now.Sub(gc.presence.lastOpen) < quiet
+	if !recent {
+		gc.presence.lastEvent = now
+	}
+	gc.presence.locker.Unlock()
+	if recent {
+		return
+	}
+	fmt.Println("Presence at gate", gc.Name+":", strings.Join(zones, ", "))
+	gl := GateLog{
+		OpenedName:  "Motion: " + strings.Join(zones, ", "),
+		EventType:   GateEvent_Presence,
+		GateName:    gc.Name,
+		TimeOpened:  now,
+		Success:     false,
+		GatePicture: frame,
+	}
+	_, err := DB.GateLogInsert(&gl)
+	if err != nil {
+		fmt.Println("Error inserting GateLog:", err)
+	} else {
+		gc.SaveClip(&gl)
+	}
+	go SaveCSVLog(gl, CONFIG.LogsDir)
+	if M.AlertSecs > 0 {
+		wait := time.Duration(M.AlertSecs) * time.Second
+		time.AfterFunc(wait, func() { gc.presenceAlert(now, frame, wait) })
+	}
+}
+
+// presenceAlert lets the admins know if the gate was not opened after the presence event
+func (gc *GateConfig) presenceAlert(at time.Time, picture []byte, wait time.Duration) {
+	gc.presence.locker.Lock()
+	opened := gc.presence.lastOpen.After(at)
+	gc.presence.locker.Unlock()
+	if opened {
+		return
+	}
+	fmt.Println(Message(defaultLanguage, Msg_AlertPresence, gc.Name, wait.String()))
+	NotifyAdmins(picture, Msg_AlertSubject, Msg_AlertPresence, gc.Name, wait.String())
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GateSensor is an optional limit switch which reports whether the gate is actually open
+type GateSensor struct {
+	GpioPin       uint32 `json:"gpio_num"`
+	OpenHigh      bool   `json:"open_when_high"`              //input reads high while the gate is open (default: low = open)
+	PullUp        bool   `json:"pull_up"`                     //use the pull-up resistor (default: pull-down)
+	LeftOpenSecs  int    `json:"left_open_alert_seconds"`     //alert when the gate stays open this long (0 = no alert)
+	OpenCheckSecs int    `json:"open_check_seconds"`          //alert if the gate has not opened this long after a command (0 = no check)
+	ForcedAlert   bool   `json:"forced_entry_alert"`          //alert when the gate opens without a PIN/web request (turn off if exits open the gate automatically)
+	ForcedWindow  int    `json:"forced_entry_window_seconds"` //how long after a request the gate may start opening (default 30)
+}
+
+func (S *GateSensor) forcedWindow() time.Duration {
+	if S.ForcedWindow < 1 {
+		return 30 * time.Second
+	}
+	return time.Duration(S.ForcedWindow) * time.Second
+}
+
+const (
+	GateState_Unknown = "unknown"
+	GateState_Open    = "open"
+	GateState_Closed  = "closed"
+)
+
+// How long the sensor needs to be stable before the change counts
+const sensorDebounce = 100 * time.Millisecond
+
+// Number of state changes kept for the gate tab
+const gateHistoryMax = 10
+
+// GateStateChange is a single change of the gate position reported by the sensor
+type GateStateChange struct {
+	State string
+	Time  time.Time
+}
+
+// gateStatus is the position tracking for a gate with a sensor
+type gateStatus struct {
+	state       string
+	since       time.Time
+	history     []GateStateChange //newest first
+	lastCommand time.Time         //last time the gate was told to open
+	expectOpen  chan bool         //sent after every open command
+	done        chan struct{}
+	locker      sync.Mutex
+}
+
+func (gc *GateConfig) HasSensor() bool {
+	return gc.Sensor != nil
+}
+
+// StartSensor starts watching the sensor input for the gate (if there is one)
+func (gc *GateConfig) StartSensor() error {
+	S := gc.Sensor
+	if S == nil {
+		return nil
+	}
+	if S.GpioPin < 1 {
+		return fmt.Errorf("No Sensor GPIO configured")
+	}
+	GPIO.SetInput(S.GpioPin)
+	if S.PullUp {
+		GPIO.SetPinUp(S.GpioPin)
+	} else {
+		GPIO.SetPinDown(S.GpioPin)
+	}
+	edges, err := GPIO.WatchEdges([]uint32{S.GpioPin})
+	if err != nil {
+		return err
+	}
+	gc.status.expectOpen = make(chan bool, 4)
+	gc.status.done = make(chan struct{})
+	gc.setState(gc.readSensor())
+	go gc.watchSensor(edges)
+	return nil
+}
+
+func (gc *GateConfig) stopSensor() {
+	if gc.status.done != nil {
+		close(gc.status.done)
+	}
+}
+
+func (gc *GateConfig) readSensor() string {
+	level, ok := GPIO.ReadPins([]uint32{gc.Sensor.GpioPin})[gc.Sensor.GpioPin]
+	if !ok || level == PIN_UNKNOWN {
+		return GateState_Unknown
+	}
+	if (level == PIN_UP) == gc.Sensor.OpenHigh {
+		return GateState_Open
+	}
+	return GateState_Closed
+}
+
+// setState records the gate position and returns true if it changed
+func (gc *GateConfig) setState(state string) bool {
+	gc.status.locker.Lock()
+	defer gc.status.locker.Unlock()
+	if gc.status.state == state {
+		return false
+	}
+	now := time.Now()
+	gc.status.state = state
+	gc.status.since = now
+	gc.status.history = append([]GateStateChange{{State: state, Time: now}}, gc.status.history...)
+	if len(gc.status.history) > gateHistoryMax {
+		gc.status.history = gc.status.history[:gateHistoryMax]
+	}
+	return true
+}
+
+// State returns the current gate position and when it last changed
+func (gc *GateConfig) State() (string, time.Time) {
+	if gc.Sensor == nil {
+		return GateState_Unknown, time.Time{}
+	}
+	gc.status.locker.Lock()
+	defer gc.status.locker.Unlock()
+	return gc.status.state, gc.status.since
+}
+
+// StateString is used by the gate tab ("Closed since 3:04PM")
+func (gc *GateConfig) StateString() string {
+	state, since := gc.State()
+	switch state {
+	case GateState_Open:
+		return "Open since " + since.Format("Jan _2 3:04:05PM")
+	case GateState_Closed:
+		return "Closed since " + since.Format("Jan _2 3:04:05PM")
+	}
+	return "Unknown"
+}
+
+// StateHistory returns the recent changes in the gate position (newest first)
+func (gc *GateConfig) StateHistory() []GateStateChange {
+	if gc.Sensor == nil {
+		return nil
+	}
+	gc.status.locker.Lock()
+	defer gc.status.locker.Unlock()
+	return append([]GateStateChange{}, gc.status.history...)
+}
+
+// commandedOpen lets the sensor watcher know that the gate should be opening now
+func (gc *GateConfig) commandedOpen() {
+	if gc.Sensor == nil || gc.status.expectOpen == nil {
+		return
+	}
+	gc.status.locker.Lock()
+	gc.status.lastCommand = time.Now()
+	gc.status.locker.Unlock()
+	select {
+	case gc.status.expectOpen <- true:
+	default:
+		//check already pending
+	}
+}
+
+// authorizedOpen reports whether the gate was told to open recently (or is being held open)
+func (gc *GateConfig) authorizedOpen() bool {
+	if gc.HoldLabel() != "" {
+		return true
+	}
+	gc.status.locker.Lock()
+	defer gc.status.locker.Unlock()
+	return time.Since(gc.status.lastCommand) < gc.Sensor.forcedWindow()
+}
+
+// watchSensor is the only goroutine which changes the gate position and runs the alarm timers
+func (gc *GateConfig) watchSensor(edges <-chan PinEvent) {
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	leftOpen := time.NewTimer(time.Hour)
+	leftOpen.Stop()
+	openCheck := time.NewTimer(time.Hour)
+	openCheck.Stop()
+	leftOpenWait := time.Duration(gc.Sensor.LeftOpenSecs) * time.Second
+	openCheckWait := time.Duration(gc.Sensor.OpenCheckSecs) * time.Second
+	if state, _ := gc.State(); state == GateState_Open && leftOpenWait > 0 {
+		resetTimer(leftOpen, leftOpenWait)
+	}
+	for {
+		select {
+		case <-gc.status.done:
+			return
+		case _, ok := <-edges:
+			if !ok {
+				return
+			}
+			resetTimer(debounce, sensorDebounce)
+		case <-gc.status.expectOpen:
+			if state, _ := gc.State(); state != GateState_Open && openCheckWait > 0 {
+				resetTimer(openCheck, openCheckWait)
+			}
+		case <-debounce.C:
+			prev, _ := gc.State()
+			state := gc.readSensor()
+			if !gc.setState(state) {
+				continue //bounced back to the same state
+			}
+			fmt.Println("Gate", gc.Name, "is now", state)
+			if state == GateState_Open {
+				if prev == GateState_Closed && gc.Sensor.ForcedAlert && !gc.authorizedOpen() {
+					go gc.sensorAlert(GateEvent_Forced, Msg_AlertForced)
+				}
+				stopTimer(openCheck)
+				if leftOpenWait > 0 {
+					resetTimer(leftOpen, leftOpenWait)
+				}
+			} else {
+				stopTimer(leftOpen)
+			}
+		case <-leftOpen.C:
+			if gc.HoldLabel() != "" {
+				// Held open on purpose - check again later
+				resetTimer(leftOpen, leftOpenWait)
+				continue
+			}
+			go gc.sensorAlert(GateEvent_LeftOpen, Msg_AlertLeftOpen, leftOpenWait.String())
+		case <-openCheck.C:
+			go gc.sensorAlert(GateEvent_OpenFailed, Msg_AlertOpenFailed)
+		}
+	}
+}
+
+// sensorAlert logs the problem and lets the admins know
+func (gc *GateConfig) sensorAlert(event string, problem string, args ...interface{}) {
+	fmt.Println(Message(defaultLanguage, problem, append([]interface{}{gc.Name}, args...)...))
+	gl := GateLog{
+		OpenedName:  "Gate Sensor",
+		EventType:   event,
+		GateName:    gc.Name,
+		TimeOpened:  time.Now(),
+		Success:     event != GateEvent_OpenFailed,
+		GatePicture: gc.TakePicture(),
+	}
+	_, err := DB.GateLogInsert(&gl)
+	if err != nil {
+		fmt.Println("Error inserting GateLog:", err)
+	} else {
+		gc.SaveClip(&gl)
+	}
+	go SaveCSVLog(gl, CONFIG.LogsDir)
+	NotifyAdmins(gl.GatePicture, Msg_AlertSubject, problem, append([]interface{}{gc.Name}, args...)...)
+}
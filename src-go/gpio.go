@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// GPIOBackend is the set of pin operations needed by the gate and keypad.
+// Pin numbers are always the GPIO numbers (not the physical header pins)
+type GPIOBackend interface {
+	SetInput(pin uint32) error
+	SetOutput(pin uint32) error
+	SetPinUp(pin uint32) error   //Enable the pull-up resistor
+	SetPinDown(pin uint32) error //Enable the pull-down resistor
+	SetOutputDriveHigh(pin uint32) error
+	SetOutputDriveLow(pin uint32) error
+	ReadPins(pins []uint32) map[uint32]PinState //PIN_UP = high, PIN_DOWN = low
+	WatchEdges(pins []uint32) (<-chan PinEvent, error)
+	Close()
+}
+
+// PinEvent is a single level change on an input pin
+type PinEvent struct {
+	Pin   uint32
+	State PinState
+	Time  time.Time
+	Stamp uint64 //kernel timestamp (ns) - puts events from different pins in order (0 if the backend has none)
+}
+
+// pollEdges emulates edge events for backends which can only read the current levels
+func pollEdges(read func([]uint32) map[uint32]PinState, pins []uint32, interval time.Duration, done <-chan struct{}) <-chan PinEvent {
+	events := make(chan PinEvent, 64)
+	go func() {
+		defer close(events)
+		prev := read(pins)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			now := read(pins)
+			for pin, state := range now {
+				if old, ok := prev[pin]; ok && old != state {
+					events <- PinEvent{Pin: pin, State: state, Time: time.Now()}
+				}
+			}
+			prev = now
+		}
+	}()
+	return events
+}
+
+const (
+	GPIO_PINCTRL = "pinctrl" //Shell out to the "pinctrl" utility (Raspberry Pi OS)
+	GPIO_CHARDEV = "chardev" //Linux GPIO character device (/dev/gpiochipN)
+	GPIO_SIM     = "sim"     //In-memory simulator (no hardware needed)
+)
+
+type GPIOConfig struct {
+	Backend string `json:"backend"` //pinctrl, chardev, or sim (blank = chardev on arm64 if the chip is there, then pinctrl, sim otherwise)
+	Chip    string `json:"chip"`    //chardev only: path to the gpiochip device
+}
+
+func (G GPIOConfig) chip() string {
+	if G.Chip == "" {
+		return "/dev/gpiochip0"
+	}
+	return G.Chip
+}
+
+func (G GPIOConfig) BackendName() string {
+	if G.Backend != "" {
+		return G.Backend
+	}
+	if runtime.GOARCH == "arm64" {
+		// pinctrl has to keep polling the pins (a new process every time), so only use it when there is no chip device
+		if _, err := os.Stat(G.chip()); err == nil && runtime.GOOS == "linux" {
+			return GPIO_CHARDEV
+		}
+		return GPIO_PINCTRL
+	}
+	return GPIO_SIM
+}
+
+func (G GPIOConfig) NewBackend() (GPIOBackend, error) {
+	switch G.BackendName() {
+	case GPIO_PINCTRL:
+		return &PinctrlGPIO{done: make(chan struct{})}, nil
+	case GPIO_CHARDEV:
+		cd, err := NewChardevGPIO(G.chip())
+		if err != nil {
+			return nil, err
+		}
+		return cd, nil
+	case GPIO_SIM:
+		return NewSimGPIO(), nil
+	default:
+		return nil, fmt.Errorf("Unknown GPIO backend: %s", G.Backend)
+	}
+}
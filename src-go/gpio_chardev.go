@@ -0,0 +1,294 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Linux GPIO character device (gpiochip v2 uAPI)
+// Reference: https://www.kernel.org/doc/html/latest/userspace-api/gpio/chardev.html
+// Every pin gets requested as a single line, and the line stays open until Close()
+
+const (
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+
+	gpioV2LineAttrIDOutputValues = 2
+
+	gpioV2LineEventRisingEdge = 1
+	gpioV2LineEventSize       = 48 // struct gpio_v2_line_event
+
+	// _IOWR(0xB4, nr, size)
+	gpioV2GetLineIoctl       = 0xC250B407 // struct gpio_v2_line_request (592 bytes)
+	gpioV2LineSetConfigIoctl = 0xC110B40D // struct gpio_v2_line_config (272 bytes)
+	gpioV2LineGetValuesIoctl = 0xC010B40E // struct gpio_v2_line_values (16 bytes)
+	gpioV2LineSetValuesIoctl = 0xC010B40F // struct gpio_v2_line_values (16 bytes)
+	gpioBiasMask             = gpioV2LineFlagBiasPullUp | gpioV2LineFlagBiasPullDown
+	gpioDirectionMask        = gpioV2LineFlagInput | gpioV2LineFlagOutput
+	gpioEdgeMask             = gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	gpioConsumerName         = "gatemaster"
+)
+
+// Kernel structures - these must match the layout in <linux/gpio.h>
+type gpioV2LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64 //union of flags/values/debounce_period_us
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [10]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [64]uint32
+	Consumer        [32]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+type chardevLine struct {
+	fd    int
+	flags uint64
+	high  bool     //last value driven (output only)
+	file  *os.File //only set once the line is being watched for edge events
+}
+
+type ChardevGPIO struct {
+	chip   *os.File
+	lines  map[uint32]*chardevLine
+	locker sync.Mutex
+}
+
+func NewChardevGPIO(chip string) (*ChardevGPIO, error) {
+	f, err := os.OpenFile(chip, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ChardevGPIO{
+		chip:  f,
+		lines: make(map[uint32]*chardevLine),
+	}, nil
+}
+
+func (G *ChardevGPIO) Close() {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	for pin, line := range G.lines {
+		if line.file != nil {
+			line.file.Close() //also stops the edge watcher
+		} else {
+			syscall.Close(line.fd)
+		}
+		delete(G.lines, pin)
+	}
+	G.chip.Close()
+}
+
+func gpioIoctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (G *ChardevGPIO) lineConfig(flags uint64, high bool) gpioV2LineConfig {
+	conf := gpioV2LineConfig{Flags: flags}
+	if flags&gpioV2LineFlagOutput != 0 {
+		conf.NumAttrs = 1
+		conf.Attrs[0].Attr.ID = gpioV2LineAttrIDOutputValues
+		if high {
+			conf.Attrs[0].Attr.Value = 1
+		}
+		conf.Attrs[0].Mask = 1
+	}
+	return conf
+}
+
+// configure requests the line the first time, then just re-configures it after that
+// NOTE: Must be called with the locker already held
+func (G *ChardevGPIO) configure(pin uint32, flags uint64, high bool) error {
+	line, ok := G.lines[pin]
+	if ok {
+		conf := G.lineConfig(flags, high)
+		if err := gpioIoctl(uintptr(line.fd), gpioV2LineSetConfigIoctl, unsafe.Pointer(&conf)); err != nil {
+			return fmt.Errorf("GPIO %d set config: %w", pin, err)
+		}
+		line.flags = flags
+		line.high = high
+		return nil
+	}
+	var req gpioV2LineRequest
+	req.Offsets[0] = pin
+	req.NumLines = 1
+	copy(req.Consumer[:], gpioConsumerName)
+	req.Config = G.lineConfig(flags, high)
+	if err := gpioIoctl(G.chip.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return fmt.Errorf("GPIO %d request line: %w", pin, err)
+	}
+	G.lines[pin] = &chardevLine{fd: int(req.Fd), flags: flags, high: high}
+	return nil
+}
+
+// updateFlags replaces the flags within the mask and keeps the rest of the current line settings
+func (G *ChardevGPIO) updateFlags(pin uint32, mask uint64, flags uint64) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	cur := uint64(gpioV2LineFlagInput)
+	high := false
+	if line, ok := G.lines[pin]; ok {
+		cur = line.flags
+		high = line.high
+	}
+	return G.configure(pin, (cur&^mask)|flags, high)
+}
+
+func (G *ChardevGPIO) SetInput(pin uint32) error {
+	return G.updateFlags(pin, gpioDirectionMask, gpioV2LineFlagInput)
+}
+
+func (G *ChardevGPIO) SetOutput(pin uint32) error {
+	return G.updateFlags(pin, gpioDirectionMask|gpioBiasMask|gpioEdgeMask, gpioV2LineFlagOutput)
+}
+
+func (G *ChardevGPIO) SetPinUp(pin uint32) error {
+	return G.updateFlags(pin, gpioBiasMask, gpioV2LineFlagBiasPullUp)
+}
+
+func (G *ChardevGPIO) SetPinDown(pin uint32) error {
+	return G.updateFlags(pin, gpioBiasMask, gpioV2LineFlagBiasPullDown)
+}
+
+func (G *ChardevGPIO) setDrive(pin uint32, high bool) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	line, ok := G.lines[pin]
+	if !ok || line.flags&gpioV2LineFlagOutput == 0 {
+		// Not an output yet - switch it over with the requested value
+		return G.configure(pin, gpioV2LineFlagOutput, high)
+	}
+	vals := gpioV2LineValues{Mask: 1}
+	if high {
+		vals.Bits = 1
+	}
+	if err := gpioIoctl(uintptr(line.fd), gpioV2LineSetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+		return fmt.Errorf("GPIO %d set value: %w", pin, err)
+	}
+	line.high = high
+	return nil
+}
+
+func (G *ChardevGPIO) SetOutputDriveHigh(pin uint32) error {
+	return G.setDrive(pin, true)
+}
+
+func (G *ChardevGPIO) SetOutputDriveLow(pin uint32) error {
+	return G.setDrive(pin, false)
+}
+
+func (G *ChardevGPIO) ReadPins(pins []uint32) map[uint32]PinState {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	state := make(map[uint32]PinState)
+	for _, pin := range pins {
+		line, ok := G.lines[pin]
+		if !ok {
+			// Never configured - request it as a plain input
+			if err := G.configure(pin, gpioV2LineFlagInput, false); err != nil {
+				fmt.Println("Got error [chardev read]:", err)
+				continue
+			}
+			line = G.lines[pin]
+		}
+		vals := gpioV2LineValues{Mask: 1}
+		if err := gpioIoctl(uintptr(line.fd), gpioV2LineGetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+			fmt.Println("Got error [chardev read]:", err)
+			continue
+		}
+		if vals.Bits&1 == 1 {
+			state[pin] = PIN_UP
+		} else {
+			state[pin] = PIN_DOWN
+		}
+	}
+	return state
+}
+
+// WatchEdges turns on edge detection for the input pins, and the kernel queues every change for us
+func (G *ChardevGPIO) WatchEdges(pins []uint32) (<-chan PinEvent, error) {
+	var files []*os.File
+	for _, pin := range pins {
+		err := G.updateFlags(pin, gpioDirectionMask|gpioEdgeMask, gpioV2LineFlagInput|gpioEdgeMask)
+		if err != nil {
+			return nil, err
+		}
+		G.locker.Lock()
+		line := G.lines[pin]
+		if line.file == nil {
+			// Non-blocking puts the reads on the Go poller, so closing the file wakes up the watcher
+			if err := syscall.SetNonblock(line.fd, true); err != nil {
+				G.locker.Unlock()
+				return nil, err
+			}
+			line.file = os.NewFile(uintptr(line.fd), fmt.Sprintf("gpio-%d", pin))
+		}
+		files = append(files, line.file)
+		G.locker.Unlock()
+	}
+	events := make(chan PinEvent, 64)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(pin uint32, file *os.File) {
+			defer wg.Done()
+			buf := make([]byte, gpioV2LineEventSize)
+			for {
+				if _, err := io.ReadFull(file, buf); err != nil {
+					return //line closed
+				}
+				// struct gpio_v2_line_event: timestamp_ns (u64), id (u32), offset (u32), ...
+				// Every line has its own goroutine, so the events can arrive out of order between the pins:
+				// the kernel timestamp is what puts them back in order
+				ev := PinEvent{Pin: pin, State: PIN_DOWN, Time: time.Now(), Stamp: binary.LittleEndian.Uint64(buf[0:8])}
+				if binary.LittleEndian.Uint32(buf[8:12]) == gpioV2LineEventRisingEdge {
+					ev.State = PIN_UP
+				}
+				events <- ev
+			}
+		}(pins[i], file)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+)
+
+// The GPIO character device only exists on Linux
+type ChardevGPIO struct {
+	PinctrlGPIO
+}
+
+func NewChardevGPIO(chip string) (*ChardevGPIO, error) {
+	return nil, fmt.Errorf("GPIO chardev backend is only supported on Linux")
+}
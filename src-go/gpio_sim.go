@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SimGPIO is an in-memory GPIO backend for running without any hardware.
+// Inputs read back their pull resistor state unless something else drives them:
+//   - SetInputLevel() forces the level on an input (a switch/sensor)
+//   - Bridge() connects two pins together (a pressed key in a matrix keypad)
+type SimGPIO struct {
+	pins     map[uint32]*simPin
+	bridges  map[[2]uint32]bool
+	watchers []*simWatcher
+	history  map[uint32][]SimLevelChange //output pins being recorded
+	locker   sync.Mutex
+}
+
+// SimLevelChange is a single change of the driven value on a recorded output pin
+type SimLevelChange struct {
+	Time time.Time
+	High bool
+}
+
+const simHistoryMax = 50
+
+type simWatcher struct {
+	pins   []uint32
+	last   map[uint32]bool
+	events chan PinEvent
+}
+
+type simPin struct {
+	output bool
+	high   bool     //driven value (output) or forced level (input)
+	forced bool     //input level forced by SetInputLevel()
+	pull   PinState //pull resistor state for inputs
+}
+
+func NewSimGPIO() *SimGPIO {
+	return &SimGPIO{
+		pins:    make(map[uint32]*simPin),
+		bridges: make(map[[2]uint32]bool),
+		history: make(map[uint32][]SimLevelChange),
+	}
+}
+
+func (G *SimGPIO) Close() {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	for _, w := range G.watchers {
+		close(w.events)
+	}
+	G.watchers = nil
+}
+
+func (G *SimGPIO) WatchEdges(pins []uint32) (<-chan PinEvent, error) {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	w := &simWatcher{
+		pins:   pins,
+		last:   make(map[uint32]bool),
+		events: make(chan PinEvent, 256),
+	}
+	for _, pin := range pins {
+		w.last[pin] = G.level(pin)
+	}
+	G.watchers = append(G.watchers, w)
+	return w.events, nil
+}
+
+// notify sends edge events for any watched pins which changed level
+// NOTE: Must be called with the locker already held
+func (G *SimGPIO) notify() {
+	for _, w := range G.watchers {
+		for _, pin := range w.pins {
+			high := G.level(pin)
+			if high == w.last[pin] {
+				continue
+			}
+			w.last[pin] = high
+			ev := PinEvent{Pin: pin, State: PIN_DOWN, Time: time.Now()}
+			if high {
+				ev.State = PIN_UP
+			}
+			select {
+			case w.events <- ev:
+			default:
+				fmt.Println("Simulated GPIO event dropped (watcher not keeping up):", pin)
+			}
+		}
+	}
+}
+
+// NOTE: Must be called with the locker already held
+func (G *SimGPIO) pin(pin uint32) *simPin {
+	p, ok := G.pins[pin]
+	if !ok {
+		p = &simPin{pull: PIN_DOWN}
+		G.pins[pin] = p
+	}
+	return p
+}
+
+func bridgeKey(a uint32, b uint32) [2]uint32 {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint32{a, b}
+}
+
+func (G *SimGPIO) SetInput(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	G.pin(pin).output = false
+	G.notify()
+	return nil
+}
+
+func (G *SimGPIO) SetOutput(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	G.pin(pin).output = true
+	G.notify()
+	return nil
+}
+
+func (G *SimGPIO) SetPinUp(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	G.pin(pin).pull = PIN_UP
+	G.notify()
+	return nil
+}
+
+func (G *SimGPIO) SetPinDown(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	G.pin(pin).pull = PIN_DOWN
+	G.notify()
+	return nil
+}
+
+func (G *SimGPIO) SetOutputDriveHigh(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	p := G.pin(pin)
+	p.output = true
+	p.high = true
+	G.record(pin, true)
+	G.notify()
+	return nil
+}
+
+func (G *SimGPIO) SetOutputDriveLow(pin uint32) error {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	p := G.pin(pin)
+	p.output = true
+	p.high = false
+	G.record(pin, false)
+	G.notify()
+	return nil
+}
+
+// NOTE: Must be called with the locker already held
+func (G *SimGPIO) level(pin uint32) bool {
+	p := G.pin(pin)
+	if p.output || p.forced {
+		return p.high
+	}
+	// Input pin: any bridged output overrides the pull resistor
+	for key, on := range G.bridges {
+		if !on || (key[0] != pin && key[1] != pin) {
+			continue
+		}
+		other := key[0]
+		if other == pin {
+			other = key[1]
+		}
+		if op, ok := G.pins[other]; ok && op.output {
+			return op.high
+		}
+	}
+	return p.pull == PIN_UP
+}
+
+func (G *SimGPIO) ReadPins(pins []uint32) map[uint32]PinState {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	state := make(map[uint32]PinState)
+	for _, pin := range pins {
+		if G.level(pin) {
+			state[pin] = PIN_UP
+		} else {
+			state[pin] = PIN_DOWN
+		}
+	}
+	return state
+}
+
+// Simulator-only functions for injecting external events
+
+// SetInputLevel forces the level seen on an input pin
+func (G *SimGPIO) SetInputLevel(pin uint32, high bool) {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	p := G.pin(pin)
+	p.forced = true
+	p.high = high
+	G.notify()
+}
+
+// ReleaseInput stops forcing an input level (back to the pull resistor state)
+func (G *SimGPIO) ReleaseInput(pin uint32) {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	G.pin(pin).forced = false
+	G.notify()
+}
+
+// Bridge connects (or disconnects) two pins, like a closed switch between them
+func (G *SimGPIO) Bridge(a uint32, b uint32, connected bool) {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	if connected {
+		G.bridges[bridgeKey(a, b)] = true
+	} else {
+		delete(G.bridges, bridgeKey(a, b))
+	}
+	G.notify()
+}
+
+// OutputHigh reports the value currently driven on an output pin
+func (G *SimGPIO) OutputHigh(pin uint32) bool {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	p := G.pin(pin)
+	return p.output && p.high
+}
+
+// RecordOutput starts keeping a history of the values driven on an output pin
+func (G *SimGPIO) RecordOutput(pin uint32) {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	if _, ok := G.history[pin]; !ok {
+		G.history[pin] = []SimLevelChange{}
+	}
+}
+
+// OutputHistory returns the recorded changes for the pin (oldest first)
+func (G *SimGPIO) OutputHistory(pin uint32) []SimLevelChange {
+	G.locker.Lock()
+	defer G.locker.Unlock()
+	return append([]SimLevelChange{}, G.history[pin]...)
+}
+
+// NOTE: Must be called with the locker already held
+func (G *SimGPIO) record(pin uint32, high bool) {
+	list, ok := G.history[pin]
+	if !ok {
+		return //not recording this pin
+	}
+	if len(list) > 0 && list[len(list)-1].High == high {
+		return //no change
+	}
+	list = append(list, SimLevelChange{Time: time.Now(), High: high})
+	if len(list) > simHistoryMax {
+		list = list[len(list)-simHistoryMax:]
+	}
+	G.history[pin] = list
+}
@@ -15,11 +15,37 @@ func setupPages() {
 	http.HandleFunc("/auth-login", checkToken(performLoginHandler, false, false))
 	http.HandleFunc("/auth-logout", checkToken(performLogoutHandler, true, false))
 	http.HandleFunc("/auth-pwreset", checkToken(performPWResetHandler, false, false))
+	// Call-box approve link (signed link instead of a login)
+	http.HandleFunc("/visitor", checkToken(visitorPageHandler, false, false))
+	http.HandleFunc("/visitor-approve", checkToken(performVisitorApprove, false, false))
 	// Main Page (parent of all tabs)
 	http.HandleFunc("/gate", checkToken(gatePageHandler, true, false))
 	// View Tab
 	http.HandleFunc("/page-view", checkToken(tab_gateHandler, true, false))
 	http.HandleFunc("/gate-open", checkToken(performGateOpen, true, false))
+	http.HandleFunc("/gate-hold", checkToken(performGateHold, true, true))
+	http.HandleFunc("/gate-hold-end", checkToken(performGateHoldEnd, true, true))
+	// Hold-Open Schedules Tab
+	http.HandleFunc("/page-holds", checkToken(tab_holdsHandler, true, true))
+	http.HandleFunc("/page-hold-new", checkToken(tab_holdNewHandler, true, true))
+	http.HandleFunc("/page-hold-view", checkToken(tab_holdViewHandler, true, true))
+	http.HandleFunc("/hold-create", checkToken(performHoldCreate, true, true))
+	http.HandleFunc("/hold-update", checkToken(performHoldUpdate, true, true))
+	// LCD Messages Tab (admin only)
+	http.HandleFunc("/page-lcdmessages", checkToken(tab_lcdMessagesHandler, true, true))
+	http.HandleFunc("/page-lcdmessage-new", checkToken(tab_lcdMessageNewHandler, true, true))
+	http.HandleFunc("/page-lcdmessage-view", checkToken(tab_lcdMessageViewHandler, true, true))
+	http.HandleFunc("/lcdmessage-create", checkToken(performLCDMessageCreate, true, true))
+	http.HandleFunc("/lcdmessage-update", checkToken(performLCDMessageUpdate, true, true))
+	// Call-box Directory Tab (admin only)
+	http.HandleFunc("/page-directory", checkToken(tab_directoryHandler, true, true))
+	http.HandleFunc("/page-directory-new", checkToken(tab_directoryNewHandler, true, true))
+	http.HandleFunc("/page-directory-view", checkToken(tab_directoryViewHandler, true, true))
+	http.HandleFunc("/directory-create", checkToken(performDirectoryCreate, true, true))
+	http.HandleFunc("/directory-update", checkToken(performDirectoryUpdate, true, true))
+	// Keypad Lockouts Tab
+	http.HandleFunc("/page-lockouts", checkToken(tab_lockoutsHandler, true, true))
+	http.HandleFunc("/lockout-clear", checkToken(performLockoutClear, true, true))
 	// Accounts Tab
 	http.HandleFunc("/page-accounts", checkToken(tab_accountsHandler, true, true))
 	http.HandleFunc("/page-account-new", checkToken(tab_accountNewHandler, true, true))
@@ -48,6 +74,14 @@ func setupPages() {
 	// View Tab
 	http.HandleFunc("/page-logs", checkToken(tab_logsHandler, true, false))
 	http.HandleFunc("/page-log-view", checkToken(tab_logViewHandler, true, false))
+	http.HandleFunc("/log-clip", checkToken(serveLogClip, true, false))
+	http.HandleFunc("/log-picture", checkToken(serveLogPicture, true, false))
+	// Simulator Tab
+	http.HandleFunc("/page-simulator", checkToken(tab_simulatorHandler, true, true))
+	http.HandleFunc("/sim-status", checkToken(simStatusHandler, true, true))
+	http.HandleFunc("/sim-key", checkToken(performSimKey, true, true))
+	http.HandleFunc("/sim-sensor", checkToken(performSimSensor, true, true))
+	http.HandleFunc("/sim-card", checkToken(performSimCard, true, true))
 
 }
 
@@ -113,13 +147,11 @@ func performPWResetHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 		acc.TempPwHash = hashPassword(newpw)
 		DB.AccountUpdate(acc)
 		// Now send an email to the user with the temporary password
+		lang := DB.AccountLanguage(acc.AccountID)
 		CONFIG.Email.SendEmail(
 			user,
-			CONFIG.SiteName+" Password Reset",
-			fmt.Sprintf("A password reset has been requested for you at %s.\nPlease login and change your password as soon as possible.\n\nYour temporary password is:  %s",
-				CONFIG.Host,
-				newpw,
-			),
+			Message(lang, Msg_ResetSubject, CONFIG.SiteName),
+			Message(lang, Msg_ResetBody, CONFIG.Host, newpw),
 			true,
 		)
 	}
@@ -135,6 +167,162 @@ func tab_gateHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 	renderTemplate(w, "tab_gate", p)
 }
 
+func tab_holdsHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.Holds, _ = DB.GateHoldSelectAll(0, false)
+	renderTemplate(w, "tab_holds", p)
+}
+
+func tab_holdNewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	renderTemplate(w, "tab_hold_new", p)
+}
+
+func tab_holdViewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	id, err := strconv.Atoi(r.Form.Get("holdid"))
+	if err != nil {
+		returnError(w, "Invalid Schedule")
+		return
+	}
+	list, err := DB.GateHoldSelectAll(int64(id), false)
+	if err != nil || len(list) < 1 || list[0].IsManual {
+		returnError(w, "Invalid Schedule")
+		return
+	}
+	p.Hold = list[0]
+	renderTemplate(w, "tab_hold_view", p)
+}
+
+func tab_lcdMessagesHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.LCDMessages, _ = DB.LCDMessageSelectAll(0, false)
+	renderTemplate(w, "tab_lcdmessages", p)
+}
+
+func tab_lcdMessageNewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	renderTemplate(w, "tab_lcdmessage_new", p)
+}
+
+func tab_lcdMessageViewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	id, err := strconv.Atoi(r.Form.Get("messageid"))
+	if err != nil {
+		returnError(w, "Invalid LCD Message")
+		return
+	}
+	list, err := DB.LCDMessageSelectAll(int64(id), false)
+	if err != nil || len(list) < 1 {
+		returnError(w, "Invalid LCD Message")
+		return
+	}
+	p.LCDMessage = list[0]
+	renderTemplate(w, "tab_lcdmessage_view", p)
+}
+
+func tab_directoryHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.Directory, _ = DB.DirectorySelectAll(0)
+	//Now update the "AccountName" field inside all the entries so that we can display them
+	accounts, _ := DB.AccountsSelectAll()
+	names := make(map[int32]string)
+	for _, acc := range accounts {
+		names[acc.AccountID] = fmt.Sprintf("%s, %s", acc.LastName, acc.FirstName)
+	}
+	for i := range p.Directory {
+		p.Directory[i].AccountName = names[p.Directory[i].AccountID]
+	}
+	renderTemplate(w, "tab_directory", p)
+}
+
+func tab_directoryNewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.Accounts, _ = DB.AccountsSelectAll()
+	renderTemplate(w, "tab_directory_new", p)
+}
+
+func tab_directoryViewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	id, err := strconv.Atoi(r.Form.Get("entryid"))
+	if err != nil {
+		returnError(w, "Invalid Directory Entry")
+		return
+	}
+	list, err := DB.DirectorySelectAll(int64(id))
+	if err != nil || len(list) < 1 {
+		returnError(w, "Invalid Directory Entry")
+		return
+	}
+	p.Entry = list[0]
+	p.Accounts, _ = DB.AccountsSelectAll()
+	renderTemplate(w, "tab_directory_view", p)
+}
+
+func performDirectoryCreate(w http.ResponseWriter, r *http.Request, p *Page) {
+	E, err := LoadDirectoryFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	E.IsActive = true //new entries are always active initially
+	_, err = DB.DirectoryInsert(&E)
+	if err != nil {
+		returnError(w, "Internal error creating directory entry")
+		return
+	}
+	tab_directoryHandler(w, r, p)
+}
+
+func performDirectoryUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
+	E, err := LoadDirectoryFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	_, err = DB.DirectoryUpdate(&E)
+	if err != nil {
+		returnError(w, "Internal error updating directory entry")
+		return
+	}
+	tab_directoryHandler(w, r, p)
+}
+
+func visitorPageHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.VisitorToken = r.URL.Query().Get("t")
+	p.Visitor, _ = VisitorCallFromToken(p.VisitorToken) //nil = bad/expired link
+	renderTemplate(w, "visitor", p)
+}
+
+func performVisitorApprove(w http.ResponseWriter, r *http.Request, p *Page) {
+	r.ParseForm()
+	call, err := ApproveVisitorCall(r.Form.Get("t"))
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	p.Visitor = call
+	renderTemplate(w, "visitor_approved", p)
+}
+
+func tab_lockoutsHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.Lockouts = KeypadLockouts()
+	renderTemplate(w, "tab_lockouts", p)
+}
+
+func performLockoutClear(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	G := CONFIG.GateByName(r.Form.Get("gate"))
+	if G == nil {
+		returnError(w, "Invalid Gate")
+		return
+	}
+	err := DB.KeypadLockoutClear(G.Name)
+	if err != nil {
+		returnError(w, "Internal error clearing lockout")
+		return
+	}
+	tab_lockoutsHandler(w, r, p)
+}
+
 func tab_accountsHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 	p.Accounts, _ = DB.AccountsSelectAll()
 	renderTemplate(w, "tab_accounts", p)
@@ -263,6 +451,82 @@ func tab_logViewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 	renderTemplate(w, "tab_log_view", p)
 }
 
+// serveLogClip plays the video clip for a gate log entry (?logid=N)
+func serveLogClip(w http.ResponseWriter, r *http.Request, p *Page) {
+	id, err := strconv.Atoi(r.URL.Query().Get("logid"))
+	if err != nil {
+		http.Error(w, "Invalid Log ID", http.StatusBadRequest)
+		return
+	}
+	gl, err := DB.GateLogFromID(int64(id))
+	if err != nil || gl == nil || !gl.HasClip() || (!p.Token.IsAdmin && gl.AccountID != p.Token.UserId) {
+		http.Error(w, "Clip not found", http.StatusNotFound)
+		return
+	}
+	serveClip(w, r, gl.ClipFile)
+}
+
+// serveLogPicture sends the picture for a gate log entry (?logid=N, add &thumb=1 for the thumbnail)
+func serveLogPicture(w http.ResponseWriter, r *http.Request, p *Page) {
+	id, err := strconv.Atoi(r.URL.Query().Get("logid"))
+	if err != nil {
+		http.Error(w, "Invalid Log ID", http.StatusBadRequest)
+		return
+	}
+	gl, err := DB.GateLogFromID(int64(id))
+	if err != nil || gl == nil || !gl.HasImage() || (!p.Token.IsAdmin && gl.AccountID != p.Token.UserId) {
+		http.Error(w, "Picture not found", http.StatusNotFound)
+		return
+	}
+	DB.servePicture(w, r, gl.PictureHash, r.URL.Query().Get("thumb") != "")
+}
+
+func tab_simulatorHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	if !SimulatorEnabled() {
+		returnError(w, "Simulator not enabled")
+		return
+	}
+	p.Sims = SimCurrentStatus()
+	renderTemplate(w, "tab_simulator", p)
+}
+
+func simStatusHandler(w http.ResponseWriter, r *http.Request, p *Page) {
+	p.Sims = SimCurrentStatus()
+	renderTemplate(w, "sim_status", p)
+}
+
+func performSimKey(w http.ResponseWriter, r *http.Request, p *Page) {
+	r.ParseForm()
+	err := SimPressKey(r.Form.Get("gate"), r.Form.Get("key"))
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	simStatusHandler(w, r, p)
+}
+
+func performSimSensor(w http.ResponseWriter, r *http.Request, p *Page) {
+	r.ParseForm()
+	err := SimSetSensor(r.Form.Get("gate"), r.Form.Get("state") == GateState_Open)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	time.Sleep(2 * sensorDebounce) //let the sensor watcher see the change before showing the status
+	simStatusHandler(w, r, p)
+}
+
+func performSimCard(w http.ResponseWriter, r *http.Request, p *Page) {
+	r.ParseForm()
+	err := SimSwipeCard(r.Form.Get("gate"), r.Form.Get("card"))
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	time.Sleep(2 * wiegandFrameGap) //let the reader finish the card number before showing the status
+	simStatusHandler(w, r, p)
+}
+
 func tab_contactsHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 	var err error
 	p.Contacts, err = DB.ContactsForAccount(int64(p.Token.UserId)) //all contacts for current user
@@ -296,27 +560,167 @@ func tab_contactViewHandler(w http.ResponseWriter, r *http.Request, p *Page) {
 	renderTemplate(w, "tab_contact_view", p)
 }
 
+// serveGateStream shows the video from the camera at the gate (?gate=name)
+func serveGateStream(w http.ResponseWriter, r *http.Request, p *Page) {
+	G := CONFIG.GateByName(r.URL.Query().Get("gate"))
+	if G == nil || !G.HasCamera() {
+		http.Error(w, "No camera at this gate", http.StatusNotFound)
+		return
+	}
+	G.cam.ServeImages(w, r, p)
+}
+
 func performGateOpen(w http.ResponseWriter, r *http.Request, p *Page) {
-	fmt.Println("Gate Opening!")
+	//Parse the form
+	r.ParseForm()
+	G := CONFIG.GateByName(r.Form.Get("gate"))
+	if G == nil {
+		returnError(w, "Invalid Gate")
+		return
+	}
+	fmt.Println("Gate Opening!", G.Name)
 	acc, err := DB.AccountFromID(p.Token.UserId)
 	if err != nil {
 		// Current user no longer exists?
 		handleError(w, r)
 		return
 	}
-	_ = OpenGateAndNotify(acc, nil)
+	_ = OpenGateAndNotify(G, acc, nil)
 	returnSuccess(w, "Gate Opening!")
 }
 
+func performGateHold(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	G := CONFIG.GateByName(r.Form.Get("gate"))
+	if G == nil {
+		returnError(w, "Invalid Gate")
+		return
+	}
+	until := parseFormDateTime(r.Form.Get("holduntil"))
+	if until == nil || until.Before(time.Now()) {
+		returnError(w, "Invalid hold open time")
+		return
+	}
+	acc, err := DB.AccountFromID(p.Token.UserId)
+	if err != nil || acc == nil {
+		handleError(w, r)
+		return
+	}
+	H := GateHold{
+		AccountID: acc.AccountID,
+		Label:     fmt.Sprintf("%s %s", acc.FirstName, acc.LastName),
+		GateName:  G.Name,
+		IsActive:  true,
+		IsManual:  true,
+		HoldUntil: *until,
+	}
+	_, err = DB.GateHoldInsert(&H)
+	if err != nil {
+		returnError(w, "Internal error holding gate open")
+		return
+	}
+	RecheckHolds()
+	time.Sleep(100 * time.Millisecond) //give the scheduler a moment before reloading the status
+	tab_gateHandler(w, r, p)
+}
+
+func performGateHoldEnd(w http.ResponseWriter, r *http.Request, p *Page) {
+	//Parse the form
+	r.ParseForm()
+	G := CONFIG.GateByName(r.Form.Get("gate"))
+	if G == nil {
+		returnError(w, "Invalid Gate")
+		return
+	}
+	err := DB.GateHoldEndManual(G.Name)
+	if err != nil {
+		returnError(w, "Internal error ending gate hold")
+		return
+	}
+	RecheckHolds()
+	time.Sleep(100 * time.Millisecond) //give the scheduler a moment before reloading the status
+	tab_gateHandler(w, r, p)
+}
+
+func performHoldCreate(w http.ResponseWriter, r *http.Request, p *Page) {
+	H, err := LoadGateHoldFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	H.AccountID = p.Token.UserId
+	H.IsActive = true //new schedules are always active initially
+	_, err = DB.GateHoldInsert(&H)
+	if err != nil {
+		returnError(w, "Internal error creating schedule")
+		return
+	}
+	RecheckHolds()
+	tab_holdsHandler(w, r, p)
+}
+
+func performHoldUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
+	H, err := LoadGateHoldFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	_, err = DB.GateHoldUpdate(&H)
+	if err != nil {
+		returnError(w, "Internal error updating schedule")
+		return
+	}
+	RecheckHolds()
+	tab_holdsHandler(w, r, p)
+}
+
+func performLCDMessageCreate(w http.ResponseWriter, r *http.Request, p *Page) {
+	M, err := LoadLCDMessageFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	M.AccountID = p.Token.UserId
+	M.IsActive = true //new messages are always active initially
+	_, err = DB.LCDMessageInsert(&M)
+	if err != nil {
+		returnError(w, "Internal error creating LCD message")
+		return
+	}
+	RefreshIdleScreens()
+	tab_lcdMessagesHandler(w, r, p)
+}
+
+func performLCDMessageUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
+	M, err := LoadLCDMessageFromForm(r)
+	if err != nil {
+		returnError(w, err.Error())
+		return
+	}
+	_, err = DB.LCDMessageUpdate(&M)
+	if err != nil {
+		returnError(w, "Internal error updating LCD message")
+		return
+	}
+	RefreshIdleScreens()
+	tab_lcdMessagesHandler(w, r, p)
+}
+
 func performProfileUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
 	//Parse the form
 	r.ParseForm()
 	fname := r.Form.Get("fname")
 	lname := r.Form.Get("lname")
+	lang := r.Form.Get("language")
 	if lname == "" || fname == "" {
 		returnError(w, "Missing name(s)")
 		return
 	}
+	if lang != "" && !ValidLanguage(lang) {
+		returnError(w, "Invalid language")
+		return
+	}
 	//Update the account in the DB
 	acc, err := DB.AccountFromID(p.Token.UserId)
 	if err != nil {
@@ -326,6 +730,7 @@ func performProfileUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
 	}
 	acc.FirstName = fname
 	acc.LastName = lname
+	acc.Language = lang
 	_, err = DB.AccountUpdate(acc)
 	if err != nil {
 		//Error
@@ -388,11 +793,16 @@ func performAccountCreate(w http.ResponseWriter, r *http.Request, p *Page) {
 	newpw := RandomPIN(10) //use numbers only - easier to read/change
 	//newpw2 := r.Form.Get("newpw2")
 	isadmin := r.Form.Get("isadmin") == formChecked
+	lang := r.Form.Get("language")
 	// Validate the inputs
 	if fname == "" || lname == "" {
 		returnError(w, "Missing first/last name(s)")
 		return
 	}
+	if lang != "" && !ValidLanguage(lang) {
+		returnError(w, "Invalid language")
+		return
+	}
 	if ok, reasons := validatePasswordFormat(newpw); !ok {
 		returnError(w, fmt.Sprintf("Invalid Password format: %s", reasons))
 		return
@@ -412,6 +822,7 @@ func performAccountCreate(w http.ResponseWriter, r *http.Request, p *Page) {
 		LastName:      lname,
 		Username:      uname,
 		AccountStatus: accstatus,
+		Language:      lang,
 		TempPwHash:    hashPassword(newpw),
 	}
 	nacc, err := DB.AccountInsert(&acc)
@@ -435,14 +846,11 @@ func performAccountCreate(w http.ResponseWriter, r *http.Request, p *Page) {
 		return
 	}
 	//Send an email containing the new password to the account holder
+	lang = DB.AccountLanguage(nacc.AccountID)
 	CONFIG.Email.SendEmail(
 		uname,
-		"New "+CONFIG.SiteName+" Account",
-		fmt.Sprintf("%s has just created an account for you at %s.\nPlease login and change your password as soon as possible.\n\nYour temporary password is:  %s",
-			nacc.FirstName+" "+nacc.LastName,
-			CONFIG.Host,
-			newpw,
-		),
+		Message(lang, Msg_NewAcctSubject, CONFIG.SiteName),
+		Message(lang, Msg_NewAcctBody, nacc.FirstName+" "+nacc.LastName, CONFIG.Host, newpw),
 		true,
 	)
 	//Now reload the accounts page
@@ -456,11 +864,16 @@ func performAccountUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
 	fname := r.Form.Get("fname")
 	lname := r.Form.Get("lname")
 	status := r.Form.Get("status")
+	lang := r.Form.Get("language")
 	// Validate the inputs
 	if fname == "" || lname == "" {
 		returnError(w, "Missing first/last name(s)")
 		return
 	}
+	if lang != "" && !ValidLanguage(lang) {
+		returnError(w, "Invalid language")
+		return
+	}
 	accnum, err := strconv.Atoi(accid)
 	if accid == "" || err != nil {
 		returnError(w, "Invalid Account")
@@ -486,6 +899,7 @@ func performAccountUpdate(w http.ResponseWriter, r *http.Request, p *Page) {
 	}
 	acc.FirstName = fname
 	acc.LastName = lname
+	acc.Language = lang
 
 	_, err = DB.AccountUpdate(acc)
 	if err != nil {
@@ -506,15 +920,27 @@ func performAccountcodeCreate(w http.ResponseWriter, r *http.Request, p *Page) {
 	}
 	acc.AccountID = p.Token.UserId //Always associate new PIN with current user account
 	acc.IsActive = true            //new PINs are always active initially
-	if acc.CodeLength < 4 {
-		acc.CodeLength = 4
+	if acc.IsCard() {
+		if DB.AccountCodeInUse(acc.Code) {
+			returnError(w, "That card is already registered")
+			return
+		}
+		if _, err = DB.AccountCodeInsert(&acc); err != nil {
+			returnError(w, "Internal error creating card")
+			return
+		}
+		tab_accountcodesHandler(w, r, p)
+		return
+	}
+	if acc.CodeLength < CONFIG.PinEntry.minLength() {
+		acc.CodeLength = CONFIG.PinEntry.minLength()
 	}
 	acc.Code = RandomPIN(acc.CodeLength)
 
 	// New Code Validation
 	tries := 1000 //max number of tries before erroring (should never be a problem)
 	for {
-		if ac, _ := DB.AccountCodeMatch(acc.Code); ac != nil {
+		if DB.AccountCodePINTaken(acc.Code) || acc.Code == acc.DuressCode {
 			if tries <= 0 {
 				returnError(w, "Internal error creating PIN code")
 				return
@@ -617,10 +1043,11 @@ func performContactTest(w http.ResponseWriter, r *http.Request, p *Page) {
 		return
 	}
 	//Now send a test email to that contact
+	lang := p.Contact.MessageLanguage()
 	err = CONFIG.Email.SendEmail(
 		p.Contact.ContactEmail(),
-		"Notification Test",
-		fmt.Sprintf("This is a test of the %s notification system", CONFIG.SiteName),
+		Message(lang, Msg_TestSubject),
+		Message(lang, Msg_TestBody, CONFIG.SiteName),
 		false,
 	)
 	if err == nil {
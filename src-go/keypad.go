@@ -1,17 +1,438 @@
-//go:build !arm64
-
 package main
 
-type Keypad struct{}
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	4x3 Keypad (default)
+	 [1, 2, 3]
+	 [4, 5, 6]
+	 [7, 8, 9]
+	 [*, 0, #]
+
+	4x4 Keypad
+	 [1, 2, 3, A]
+	 [4, 5, 6, B]
+	 [7, 8, 9, C]
+	 [*, 0, #, D]
 
-func (K *Keypad) StartWatching() {
+Examples:
+R1 + C1 = Key "1"
+R2 + C3 = Key "6"
+*/
+type Keypad struct {
+	// Configuration from file
+	Type   string `json:"type,omitempty"`   //matrix (default) or evdev
+	Device string `json:"device,omitempty"` //evdev only: /dev/input/eventN (or a recording of one)
+	// Matrix keypads
+	// Older 4x3 configs use the numbered row/col fields instead of the pin lists
+	R1 uint32 `json:"row1,omitempty"`
+	R2 uint32 `json:"row2,omitempty"`
+	R3 uint32 `json:"row3,omitempty"`
+	R4 uint32 `json:"row4,omitempty"`
+	C1 uint32 `json:"col1,omitempty"`
+	C2 uint32 `json:"col2,omitempty"`
+	C3 uint32 `json:"col3,omitempty"`
+	// Any size of keypad
+	Rows     []uint32          `json:"rows,omitempty"`
+	Cols     []uint32          `json:"cols,omitempty"`
+	Layout   []string          `json:"layout,omitempty"`        //keys for each row ("123A") - default depends on the size
+	Actions  map[string]string `json:"function_keys,omitempty"` //key -> action (default: "*" = clear, "#" = enter)
+	HelpText string            `json:"help_message,omitempty"`  //shown by the "help" action
+	// Internal variables
+	rows     []uint32        `json:"-"`
+	cols     []uint32        `json:"-"`
+	layout   [][]string      `json:"-"`
+	events   chan KeyEvent   `json:"-"` //debounced key presses/releases from the scanner
+	displays chan lcdRequest `json:"-"` //LCD updates to run on the keypad goroutine
+	done     chan struct{}   `json:"-"`
+	gate     *GateConfig     `json:"-"` //gate the keypad is mounted beside
+	lastCall time.Time       `json:"-"` //last "call resident" (only used on the keypad goroutine)
+	device   *os.File        `json:"-"` //evdev only
+	devLock  sync.Mutex      `json:"-"`
+}
+
+// KeyEvent is a single debounced change of a key on the keypad
+type KeyEvent struct {
+	Key     string
+	Pressed bool //false when the key is released
+}
 
+type lcdRequest struct {
+	icon    string
+	text    string
+	seconds int
+}
+
+// How long a column needs to be stable before the change counts
+const keyDebounce = 20 * time.Millisecond
+
+// Scanner states for the debounce logic
+const (
+	keyIdle = iota
+	keyDebouncePress
+	keyPressed
+	keyDebounceRelease
+)
+
+// Default key layouts for each number of columns
+var defaultKeyLayouts = map[int][]string{
+	3: {"123", "456", "789", "*0#"},
+	4: {"123A", "456B", "789C", "*0#D"},
+}
+
+// setupMatrix checks the rows/columns and key layout from the config
+func (K *Keypad) setupMatrix() error {
+	K.rows = K.Rows
+	K.cols = K.Cols
+	if len(K.rows) == 0 && len(K.cols) == 0 {
+		K.rows = []uint32{K.R1, K.R2, K.R3, K.R4}
+		K.cols = []uint32{K.C1, K.C2, K.C3}
+	}
+	if len(K.rows) < 1 || len(K.cols) < 1 {
+		return fmt.Errorf("keypad needs both rows and columns")
+	}
+	layout := K.Layout
+	if len(layout) == 0 {
+		layout = defaultKeyLayouts[len(K.cols)]
+	}
+	if len(layout) != len(K.rows) {
+		return fmt.Errorf("keypad layout needs %d rows of keys", len(K.rows))
+	}
+	K.layout = nil
+	for _, row := range layout {
+		keys := strings.Split(row, "")
+		if len(keys) != len(K.cols) {
+			return fmt.Errorf("keypad layout row %q needs %d keys", row, len(K.cols))
+		}
+		K.layout = append(K.layout, keys)
+	}
+	return K.setupActions(map[string]string{"*": KeyAction_Clear, "#": KeyAction_Enter})
+}
+
+// setupActions checks the function keys from the config (defaults used if none are set)
+func (K *Keypad) setupActions(defaults map[string]string) error {
+	if K.Actions == nil {
+		K.Actions = defaults
+	}
+	for key, action := range K.Actions {
+		if !validKeyAction(action) {
+			return fmt.Errorf("unknown action for key %s: %s", key, action)
+		}
+	}
+	return nil
+}
+
+func (K *Keypad) StartWatching() error {
+	if K == nil {
+		fmt.Println("No Keypad configured")
+		return nil
+	}
+	if K.Type == KeypadType_Evdev {
+		return K.startEvdev()
+	}
+	if K.Type != "" && K.Type != KeypadType_Matrix {
+		return fmt.Errorf("unknown keypad type: %s", K.Type)
+	}
+	if err := K.setupMatrix(); err != nil {
+		return err
+	}
+	// Rows are outputs and drivers - all driven high while waiting for a key
+	// Columns are inputs and what we watch for changes
+	for _, row := range K.rows {
+		GPIO.SetOutput(row)
+		GPIO.SetOutputDriveHigh(row)
+	}
+	for _, col := range K.cols {
+		GPIO.SetInput(col)
+		GPIO.SetPinDown(col)
+	}
+	edges, err := GPIO.WatchEdges(K.cols)
+	if err != nil {
+		fmt.Println("Unable to watch keypad columns:", err)
+		return nil
+	}
+	K.events = make(chan KeyEvent, 16)
+	K.displays = make(chan lcdRequest, 16)
+	K.done = make(chan struct{})
+	go K.scanKeys(edges)
+	go K.handleKeys()
+	return nil
 }
 
 func (K *Keypad) Close() {
+	if K == nil || K.done == nil {
+		return
+	}
+	close(K.done)
+	K.devLock.Lock()
+	if K.device != nil {
+		K.device.Close() //also stops the evdev reader
+	}
+	K.devLock.Unlock()
+}
+
+// resetTimer safely restarts a timer that may or may not have fired already
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// stopTimer stops a timer and clears out any value which already fired
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
 
+// scanKeys is the only goroutine which touches the keypad pins.
+// A column edge starts the debounce timer, and the matrix is only scanned
+// once the column has settled down.
+func (K *Keypad) scanKeys(edges <-chan PinEvent) {
+	state := keyIdle
+	current := ""
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	for {
+		select {
+		case <-K.done:
+			return
+		case ev, ok := <-edges:
+			if !ok {
+				return
+			}
+			switch state {
+			case keyIdle:
+				if ev.State == PIN_UP {
+					state = keyDebouncePress
+					resetTimer(debounce, keyDebounce)
+				}
+			case keyPressed:
+				if ev.State == PIN_DOWN {
+					state = keyDebounceRelease
+					resetTimer(debounce, keyDebounce)
+				}
+			default:
+				// Still bouncing - wait for it to settle again
+				resetTimer(debounce, keyDebounce)
+			}
+		case <-debounce.C:
+			switch state {
+			case keyDebouncePress:
+				current = K.scanMatrix()
+				drainEdges(edges) //the scan itself toggles the columns
+				if current == "" {
+					state = keyIdle
+				} else {
+					state = keyPressed
+					K.events <- KeyEvent{Key: current, Pressed: true}
+				}
+			case keyDebounceRelease:
+				if K.anyColumnHigh() {
+					state = keyPressed //false alarm - still held down
+				} else {
+					state = keyIdle
+					K.events <- KeyEvent{Key: current, Pressed: false}
+					current = ""
+				}
+			}
+		}
+	}
 }
 
+func drainEdges(edges <-chan PinEvent) {
+	for {
+		select {
+		case <-edges:
+		default:
+			return
+		}
+	}
+}
+
+// scanMatrix drives one row at a time to find the key being held down
+// All the rows are driven high again when finished
+func (K *Keypad) scanMatrix() string {
+	for _, row := range K.rows {
+		GPIO.SetOutputDriveLow(row)
+	}
+	key := ""
+	for r, row := range K.rows {
+		GPIO.SetOutputDriveHigh(row)
+		state := GPIO.ReadPins(K.cols)
+		GPIO.SetOutputDriveLow(row)
+		for c, col := range K.cols {
+			if state[col] == PIN_UP {
+				key = K.layout[r][c]
+				break
+			}
+		}
+		if key != "" {
+			break
+		}
+	}
+	for _, row := range K.rows {
+		GPIO.SetOutputDriveHigh(row)
+	}
+	return key
+}
+
+// keyPins returns the row/column pins which connect for the key
+func (K *Keypad) keyPins(key string) (row uint32, col uint32, ok bool) {
+	if K == nil {
+		return 0, 0, false
+	}
+	for r, keys := range K.layout {
+		for c, k := range keys {
+			if k == key {
+				return K.rows[r], K.cols[c], true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func (K *Keypad) anyColumnHigh() bool {
+	for _, state := range GPIO.ReadPins(K.cols) {
+		if state == PIN_UP {
+			return true
+		}
+	}
+	return false
+}
+
+// handleKeys owns the pending PIN and the clear timer - nothing else touches them
+func (K *Keypad) handleKeys() {
+	pin_cache := ""
+	var menu *Account //admin using the keypad menu (nil = no menu)
+	cltimer := time.NewTimer(time.Hour)
+	cltimer.Stop() //not needed initially
+	subtimer := time.NewTimer(time.Hour)
+	subtimer.Stop() //only used for auto-submit
+	for {
+		select {
+		case <-K.done:
+			return
+		case <-cltimer.C:
+			pin_cache = ""
+			menu = nil
+		case <-subtimer.C:
+			if pin_cache != "" && menu == nil {
+				pin_cache = K.EnterPressed(pin_cache)
+			}
+		case req := <-K.displays:
+			K.gate.LCD.ShowIcon(req.icon, req.text, LCDPriority_Prompt, req.seconds)
+			if req.seconds > 0 {
+				resetTimer(cltimer, time.Duration(req.seconds)*time.Second)
+			}
+		case ev := <-K.events:
+			if !ev.Pressed {
+				continue //only act on the initial press - holding a key does nothing else
+			}
+			stopTimer(subtimer) //any key cancels a pending auto-submit
+			if menu != nil {
+				K.MenuPressed(menu, ev.Key)
+				menu = nil
+				continue
+			}
+			switch K.Actions[ev.Key] {
+			case KeyAction_Clear:
+				pin_cache = ""
+				K.ClearPressed()
+			case KeyAction_Enter:
+				pin_cache = K.EnterPressed(pin_cache)
+			case KeyAction_Help:
+				pin_cache = ""
+				K.HelpPressed()
+			case KeyAction_Call:
+				pin_cache = K.CallPressed(pin_cache)
+			case KeyAction_Admin:
+				menu = K.AdminPressed(pin_cache)
+				pin_cache = ""
+			default:
+				if isDigitKey(ev.Key) {
+					pin_cache = K.NumPressed(pin_cache, ev.Key)
+					if CONFIG.PinEntry.autoSubmitReady(pin_cache) {
+						resetTimer(subtimer, pinAutoSubmitDelay)
+					}
+				}
+				// Letter keys without an action do nothing
+			}
+		}
+	}
+}
+
+func (K *Keypad) NumPressed(pin_cache string, num string) string {
+	pin_cache += num
+	if len(pin_cache) > CONFIG.PinEntry.maxLength() {
+		K.ClearPressed()
+		return ""
+	}
+	K.DisplayOnLCD(K.withStatus(CONFIG.PinEntry.masked(pin_cache), K.helpMessage()), CONFIG.PinEntry.digitTimeout()) //wipe the input if nothing else is typed
+	return pin_cache
+}
+
+func (K *Keypad) EnterPressed(pin_cache string) string {
+	err := errors.New(SiteMessage(Msg_PinNeeded))
+	if len(pin_cache) >= CONFIG.PinEntry.minLength() {
+		err = CheckPINAndOpen(K.gate, pin_cache)
+	}
+	if err != nil {
+		K.DisplayIconOnLCD(errorIcon(err.Error()), err.Error(), 2)
+	} else {
+		K.gate.LCD.Remove(LCDPriority_Prompt) //the gate shows the welcome, then back to the idle screen
+	}
+	return ""
+}
+
+func (K *Keypad) ClearPressed() {
+	K.gate.LCD.Remove(LCDPriority_Prompt)
+}
+
+// withStatus puts the status under the prompt when the LCD has more than one line
+func (K *Keypad) withStatus(prompt string, status string) string {
+	if K.gate.LCD.Rows() < 2 || status == "" {
+		return prompt
+	}
+	return prompt + "\n" + status
+}
+
+// DisplayOnLCD shows the keypad prompt, and then takes it down (and clears the pending PIN) after the number of seconds
 func (K *Keypad) DisplayOnLCD(text string, seconds int) {
+	K.DisplayIconOnLCD("", text, seconds)
+}
+
+// DisplayIconOnLCD is DisplayOnLCD with an icon (on displays which can show one)
+func (K *Keypad) DisplayIconOnLCD(icon string, text string, seconds int) {
+	if K.displays == nil {
+		K.gate.LCD.ShowIcon(icon, text, LCDPriority_Prompt, seconds)
+		return
+	}
+	select {
+	case K.displays <- lcdRequest{icon: icon, text: text, seconds: seconds}:
+	default:
+		K.gate.LCD.ShowIcon(icon, text, LCDPriority_Prompt, seconds) //queue is full - just show it now
+	}
+}
 
+// errorIcon picks the icon for a keypad error message
+func errorIcon(msg string) string {
+	if msg == SiteMessage(Msg_KeypadLocked) {
+		return LCDIcon_Locked
+	}
+	return LCDIcon_Error
 }
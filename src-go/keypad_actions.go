@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Actions which can be assigned to the keypad function keys
+const (
+	KeyAction_Clear = "clear"         //wipe the PIN being entered
+	KeyAction_Enter = "enter"         //submit the PIN
+	KeyAction_Call  = "call_resident" //unit number followed by this key calls the resident from the directory
+	KeyAction_Help  = "help"          //show the help message on the LCD
+	KeyAction_Admin = "admin_menu"    //admin PIN followed by this key opens the keypad menu
+)
+
+// Minimum time between calls from the same keypad
+const keypadCallCooldown = time.Minute
+
+// How long the admin keypad menu holds the gate open
+const keypadMenuHold = time.Hour
+
+func validKeyAction(action string) bool {
+	switch action {
+	case KeyAction_Clear, KeyAction_Enter, KeyAction_Call, KeyAction_Help, KeyAction_Admin:
+		return true
+	}
+	return false
+}
+
+func isDigitKey(key string) bool {
+	return len(key) == 1 && key[0] >= '0' && key[0] <= '9'
+}
+
+// actionKey returns the key assigned to the action (blank if none)
+func (K *Keypad) actionKey(action string) string {
+	for key, a := range K.Actions {
+		if a == action {
+			return key
+		}
+	}
+	return ""
+}
+
+func (K *Keypad) helpMessage() string {
+	if K.HelpText != "" {
+		return K.HelpText
+	}
+	return SiteMessage(Msg_EnterPIN, K.actionKey(KeyAction_Enter))
+}
+
+func (K *Keypad) HelpPressed() {
+	K.DisplayOnLCD(K.helpMessage(), 5)
+}
+
+// CallPressed calls the resident for the unit number typed before the key
+func (K *Keypad) CallPressed(pin_cache string) string {
+	if pin_cache == "" {
+		K.DisplayOnLCD(SiteMessage(Msg_UnitThenCall, K.actionKey(KeyAction_Call)), 3)
+		return ""
+	}
+	if time.Since(K.lastCall) < keypadCallCooldown {
+		K.DisplayOnLCD(SiteMessage(Msg_PleaseWait), 2)
+		return ""
+	}
+	label, err := StartVisitorCall(K.gate, pin_cache)
+	if err != nil {
+		K.DisplayOnLCD(err.Error(), 2)
+		return ""
+	}
+	K.lastCall = time.Now()
+	K.DisplayOnLCD(SiteMessage(Msg_Calling, label), 10)
+	return ""
+}
+
+// AdminPressed checks for a PIN from an admin account and opens the keypad menu
+// Returns the admin account (nil if the menu was not opened)
+func (K *Keypad) AdminPressed(pin_cache string) *Account {
+	if KeypadLocked(K.gate) {
+		K.DisplayIconOnLCD(LCDIcon_Locked, SiteMessage(Msg_KeypadLocked), 2)
+		return nil
+	}
+	var acct *Account
+	ac, err := DB.AccountCodeMatch(pin_cache)
+	if err == nil && ac != nil && !ac.IsCard() && ac.IsValid() && ac.AllowsGate(K.gate.Name) {
+		acct, err = DB.AccountFromID(ac.AccountID)
+		if err != nil || acct == nil || acct.AccountStatus != Account_Admin {
+			acct = nil
+		}
+	}
+	if acct == nil {
+		msg := SiteMessage(Msg_InvalidPIN)
+		if pin_cache != "" && keypadFailed(K.gate) {
+			msg = SiteMessage(Msg_KeypadLocked)
+		}
+		K.DisplayIconOnLCD(errorIcon(msg), msg, 2)
+		return nil
+	}
+	K.DisplayOnLCD(K.withStatus(SiteMessage(Msg_AdminMenu), SiteMessage(Msg_AdminName, acct.FirstName)), 30)
+	return acct
+}
+
+// MenuPressed runs the choice from the admin keypad menu
+func (K *Keypad) MenuPressed(acct *Account, key string) {
+	switch key {
+	case "1":
+		H := GateHold{
+			AccountID: acct.AccountID,
+			Label:     fmt.Sprintf("%s %s (keypad)", acct.FirstName, acct.LastName),
+			GateName:  K.gate.Name,
+			IsActive:  true,
+			IsManual:  true,
+			HoldUntil: time.Now().Add(keypadMenuHold),
+		}
+		if _, err := DB.GateHoldInsert(&H); err != nil {
+			K.DisplayOnLCD(SiteMessage(Msg_Error), 2)
+			return
+		}
+		RecheckHolds()
+	case "2":
+		if err := DB.GateHoldEndManual(K.gate.Name); err != nil {
+			K.DisplayOnLCD(SiteMessage(Msg_Error), 2)
+			return
+		}
+		RecheckHolds()
+	default:
+		K.ClearPressed() //any other key just leaves the menu
+	}
+}
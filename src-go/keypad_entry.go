@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PinEntryConfig controls how PINs are typed on the keypads (all gates)
+type PinEntryConfig struct {
+	MinLength        int    `json:"min_length"`            //shortest PIN (never below 4)
+	MaxLength        int    `json:"max_length"`            //longest PIN - typing more digits wipes the input
+	DigitTimeoutSecs int    `json:"digit_timeout_seconds"` //wipe the input if no key is pressed for this long
+	Mask             string `json:"mask"`                  //how the typed PIN is shown: "stars" or "count"
+	AutoSubmit       bool   `json:"auto_submit"`           //submit without the enter key once the PIN is auto_submit_length long
+	AutoSubmitLength int    `json:"auto_submit_length"`    //PIN length for auto-submit (blank = max_length)
+}
+
+const (
+	PinMask_Stars = "stars" //"****"
+	PinMask_Count = "count" //"Digits: 4"
+)
+
+// Pause after the last digit before an auto-submit, so a function key can still follow the PIN
+const pinAutoSubmitDelay = 1500 * time.Millisecond
+
+func (P PinEntryConfig) minLength() int {
+	if P.MinLength < 4 {
+		return 4
+	}
+	return P.MinLength
+}
+
+func (P PinEntryConfig) maxLength() int {
+	if P.MaxLength < P.minLength() {
+		return 10
+	}
+	return P.MaxLength
+}
+
+// autoSubmitLength is the PIN length which gets entered automatically (kept inside the length limits)
+func (P PinEntryConfig) autoSubmitLength() int {
+	if P.AutoSubmitLength < P.minLength() || P.AutoSubmitLength > P.maxLength() {
+		return P.maxLength()
+	}
+	return P.AutoSubmitLength
+}
+
+func (P PinEntryConfig) digitTimeout() int {
+	if P.DigitTimeoutSecs <= 0 {
+		return 30
+	}
+	return P.DigitTimeoutSecs
+}
+
+// validLength checks a new PIN against the length limits
+func (P PinEntryConfig) validLength(pin string) bool {
+	return len(pin) >= P.minLength() && len(pin) <= P.maxLength()
+}
+
+// lengthError is the message for a PIN which does not fit the limits
+func (P PinEntryConfig) lengthError(what string) error {
+	return fmt.Errorf("%s must be %d to %d numbers", what, P.minLength(), P.maxLength())
+}
+
+// masked is what the LCD shows while the PIN is being typed
+func (P PinEntryConfig) masked(pin string) string {
+	if P.Mask == PinMask_Count {
+		return SiteMessage(Msg_PinDigits, len(pin))
+	}
+	return strings.Repeat("*", len(pin))
+}
+
+// autoSubmitReady returns true when the typed PIN is the configured auto-submit length
+// This only ever looks at the configuration: checking the stored PINs would let anybody at the keypad
+// find out how the real PINs start (without it counting as a failed attempt).
+func (P PinEntryConfig) autoSubmitReady(pin string) bool {
+	return P.AutoSubmit && len(pin) == P.autoSubmitLength()
+}
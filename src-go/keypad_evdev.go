@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	KeypadType_Matrix = "matrix" //switch matrix on the GPIO pins (default)
+	KeypadType_Evdev  = "evdev"  //USB/HID keypad through /dev/input/eventN
+)
+
+// Each evdev record is: [timeval (2 longs)] [type uint16] [code uint16] [value int32]
+const evdevEventSize = 2*strconv.IntSize/8 + 8
+
+const (
+	evdevTypeKey     = 1
+	evdevKeyRelease  = 0
+	evdevKeyPress    = 1
+	evdevReopenDelay = 5 * time.Second
+)
+
+// Linux key codes for the keys found on USB number pads (and the number row of a full keyboard)
+var evdevKeyNames = map[uint16]string{
+	2: "1", 3: "2", 4: "3", 5: "4", 6: "5", 7: "6", 8: "7", 9: "8", 10: "9", 11: "0",
+	79: "1", 80: "2", 81: "3", 75: "4", 76: "5", 77: "6", 71: "7", 72: "8", 73: "9", 82: "0",
+	28: "enter", 96: "enter",
+	14: "backspace",
+	55: "*", 98: "/", 74: "-", 78: "+", 83: ".",
+}
+
+// Layout of a standard USB number pad (only used to draw the simulator)
+var evdevSimLayout = [][]string{
+	{"backspace", "/", "*", "-"},
+	{"7", "8", "9", "+"},
+	{"4", "5", "6", "enter"},
+	{"1", "2", "3", "0"},
+}
+
+func (K *Keypad) startEvdev() error {
+	if K.Device == "" {
+		return fmt.Errorf("evdev keypad needs a device")
+	}
+	if err := K.setupActions(map[string]string{"enter": KeyAction_Enter, "*": KeyAction_Clear, "backspace": KeyAction_Clear}); err != nil {
+		return err
+	}
+	K.layout = evdevSimLayout
+	K.events = make(chan KeyEvent, 16)
+	K.displays = make(chan lcdRequest, 16)
+	K.done = make(chan struct{})
+	go K.watchEvdev()
+	go K.handleKeys()
+	return nil
+}
+
+// watchEvdev reads the device, opening it again if it goes away (unplugged USB keypad)
+// A recorded stream (a regular file) is only played back once.
+func (K *Keypad) watchEvdev() {
+	for {
+		dev, err := openEvdev(K.Device)
+		if err == nil {
+			K.devLock.Lock()
+			select {
+			case <-K.done:
+				K.devLock.Unlock()
+				dev.Close()
+				return
+			default:
+			}
+			K.device = dev
+			K.devLock.Unlock()
+			err = readEvdevKeys(dev, K.events, K.done)
+			dev.Close()
+			if err == io.EOF {
+				fmt.Println("Keypad event stream finished:", K.Device)
+				return
+			}
+		}
+		select {
+		case <-K.done:
+			return
+		case <-time.After(evdevReopenDelay):
+		}
+		fmt.Println("Keypad device error (retrying):", err)
+	}
+}
+
+// readEvdevKeys turns the raw input events into key presses/releases until the stream ends
+func readEvdevKeys(r io.Reader, events chan<- KeyEvent, done <-chan struct{}) error {
+	buf := make([]byte, evdevEventSize)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF //partial record at the end of a recording
+			}
+			return err
+		}
+		rec := buf[evdevEventSize-8:]
+		if binary.LittleEndian.Uint16(rec[0:2]) != evdevTypeKey {
+			continue //sync/scan/LED events
+		}
+		key, ok := evdevKeyNames[binary.LittleEndian.Uint16(rec[2:4])]
+		if !ok {
+			continue
+		}
+		var ev KeyEvent
+		switch int32(binary.LittleEndian.Uint32(rec[4:8])) {
+		case evdevKeyPress:
+			ev = KeyEvent{Key: key, Pressed: true}
+		case evdevKeyRelease:
+			ev = KeyEvent{Key: key, Pressed: false}
+		default:
+			continue //auto-repeat while held down
+		}
+		select {
+		case events <- ev:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// EncodeEvdevKey makes the press and release records for a key, in the same format the kernel sends
+// (used by the simulator and to build event streams for replay)
+func EncodeEvdevKey(key string) ([]byte, error) {
+	var code uint16
+	for c, name := range evdevKeyNames {
+		if name == key && c > code {
+			code = c //prefer the number pad codes
+		}
+	}
+	if code == 0 {
+		return nil, fmt.Errorf("Invalid key: %s", key)
+	}
+	now := time.Now()
+	var out []byte
+	out = append(out, evdevRecord(now, evdevTypeKey, code, evdevKeyPress)...)
+	out = append(out, evdevRecord(now, 0, 0, 0)...) //SYN_REPORT
+	out = append(out, evdevRecord(now, evdevTypeKey, code, evdevKeyRelease)...)
+	out = append(out, evdevRecord(now, 0, 0, 0)...)
+	return out, nil
+}
+
+func evdevRecord(t time.Time, evType uint16, code uint16, value int32) []byte {
+	rec := make([]byte, evdevEventSize)
+	if strconv.IntSize == 64 {
+		binary.LittleEndian.PutUint64(rec[0:], uint64(t.Unix()))
+		binary.LittleEndian.PutUint64(rec[8:], uint64(t.Nanosecond()/1000))
+	} else {
+		binary.LittleEndian.PutUint32(rec[0:], uint32(t.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(t.Nanosecond()/1000))
+	}
+	tail := rec[evdevEventSize-8:]
+	binary.LittleEndian.PutUint16(tail[0:], evType)
+	binary.LittleEndian.PutUint16(tail[2:], code)
+	binary.LittleEndian.PutUint32(tail[4:], uint32(value))
+	return rec
+}
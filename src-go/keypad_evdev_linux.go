@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const evdevGrab = 0x40044590 //EVIOCGRAB
+
+// openEvdev opens the input device and grabs it, so the key presses do not also go to a console
+func openEvdev(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		// Not f.Fd(): that switches the file to blocking reads, and then closing it would not stop the reader
+		conn, err := f.SyscallConn()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var errno syscall.Errno
+		conn.Control(func(fd uintptr) {
+			_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, evdevGrab, 1)
+		})
+		if errno != 0 {
+			f.Close()
+			return nil, errno
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// Input devices only exist on Linux, but a recorded event stream can still be played back
+func openEvdev(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Replays a recorded evdev stream through the reader and checks the key events which come out
+func TestReadEvdevKeys(t *testing.T) {
+	var stream []byte
+	for _, key := range []string{"5", "8"} {
+		rec, err := EncodeEvdevKey(key)
+		if err != nil {
+			t.Fatalf("EncodeEvdevKey(%q): %v", key, err)
+		}
+		stream = append(stream, rec...)
+	}
+	now := time.Now()
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 28, evdevKeyPress)...)
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 28, 2)...) //auto-repeat - ignored
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 28, 2)...) //auto-repeat - ignored
+	stream = append(stream, evdevRecord(now, 0, 0, 0)...)             //SYN_REPORT - ignored
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 30, 1)...) //"A" is not a keypad key - ignored
+	stream = append(stream, evdevRecord(now, 4, 4, 458792)...)        //MSC_SCAN - ignored
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 28, evdevKeyRelease)...)
+	// Recording cut off in the middle of the last record
+	stream = append(stream, evdevRecord(now, evdevTypeKey, 2, evdevKeyPress)[:evdevEventSize/2]...)
+
+	events := make(chan KeyEvent, 32)
+	err := readEvdevKeys(bytes.NewReader(stream), events, make(chan struct{}))
+	if err != io.EOF {
+		t.Fatalf("readEvdevKeys returned %v, want io.EOF", err)
+	}
+	close(events)
+	var got []KeyEvent
+	for ev := range events {
+		got = append(got, ev)
+	}
+	want := []KeyEvent{
+		{Key: "5", Pressed: true}, {Key: "5", Pressed: false},
+		{Key: "8", Pressed: true}, {Key: "8", Pressed: false},
+		{Key: "enter", Pressed: true}, {Key: "enter", Pressed: false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("key events:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestEncodeEvdevKeyInvalid(t *testing.T) {
+	if _, err := EncodeEvdevKey("Z"); err == nil {
+		t.Error("EncodeEvdevKey accepted a key which is not on the keypad")
+	}
+}
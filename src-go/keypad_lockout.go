@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// LockoutConfig limits how many bad PINs can be tried at a keypad.
+// Too many failures within the window locks the keypad, and every lockout
+// in a row doubles the length of the lock (up to the max).
+type LockoutConfig struct {
+	MaxFailures int `json:"max_failures"`     //bad PINs within the window before locking (0 = never lock)
+	WindowSecs  int `json:"window_seconds"`   //length of the sliding window for counting failures
+	LockSecs    int `json:"lock_seconds"`     //length of the first lockout
+	MaxLockSecs int `json:"max_lock_seconds"` //longest lockout
+}
+
+func (L LockoutConfig) window() time.Duration {
+	return time.Duration(L.WindowSecs) * time.Second
+}
+
+// lockFor returns how long to lock the keypad for the lockout level (1 = first lockout)
+func (L LockoutConfig) lockFor(level int) time.Duration {
+	d := time.Duration(L.LockSecs) * time.Second
+	max := time.Duration(L.MaxLockSecs) * time.Second
+	for i := 1; i < level && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// KeypadLocked reports whether the keypad at the gate is locked right now
+func KeypadLocked(gate *GateConfig) bool {
+	L, err := DB.KeypadLockoutSelect(gate.Name)
+	if err != nil {
+		return false
+	}
+	return L.IsLocked()
+}
+
+// keypadFailed records a bad PIN at the gate and returns true if the keypad is now locked
+func keypadFailed(gate *GateConfig) bool {
+	conf := CONFIG.Lockout
+	if conf.MaxFailures < 1 {
+		return false //lockouts turned off
+	}
+	now := time.Now()
+	DB.KeypadFailureInsert(gate.Name)
+	count, err := DB.KeypadFailureCount(gate.Name, now.Add(-conf.window()))
+	if err != nil || count < conf.MaxFailures {
+		return false
+	}
+	L, err := DB.KeypadLockoutSelect(gate.Name)
+	if err != nil {
+		return false
+	}
+	if L.LockedUntil.Add(conf.window()).Before(now) {
+		L.LockLevel = 0 //quiet for a while since the last lockout - start over
+	}
+	L.LockLevel++
+	L.LockedUntil = now.Add(conf.lockFor(L.LockLevel))
+	L.Failures = count
+	if DB.KeypadLockoutSave(&L) != nil {
+		return false
+	}
+	go alertLockout(gate, L)
+	return true
+}
+
+// KeypadLockouts returns the lockout state for every gate with a keypad
+func KeypadLockouts() []KeypadLockout {
+	var list []KeypadLockout
+	since := time.Now().Add(-CONFIG.Lockout.window())
+	for _, G := range CONFIG.Gates {
+		if G.Keypad == nil {
+			continue
+		}
+		L, _ := DB.KeypadLockoutSelect(G.Name)
+		L.Failures, _ = DB.KeypadFailureCount(G.Name, since)
+		list = append(list, L)
+	}
+	return list
+}
+
+func alertLockout(gate *GateConfig, L KeypadLockout) {
+	locked := L.LockedUntil.Sub(time.Now()).Round(time.Second)
+	fmt.Println("Keypad locked at gate", gate.Name, "for", locked)
+	gl := GateLog{
+		OpenedName:  fmt.Sprintf("%d bad PINs", L.Failures),
+		EventType:   GateEvent_Lockout,
+		GateName:    gate.Name,
+		TimeOpened:  time.Now(),
+		Success:     false,
+		GatePicture: gate.TakePicture(),
+	}
+	_, err := DB.GateLogInsert(&gl)
+	if err != nil {
+		fmt.Println("Error inserting GateLog:", err)
+	} else {
+		gate.SaveClip(&gl)
+	}
+	go SaveCSVLog(gl, CONFIG.LogsDir)
+	NotifyAdmins(gl.GatePicture, Msg_LockedSubject, Msg_LockedAlert, gate.Name, locked.String(), L.Failures)
+}
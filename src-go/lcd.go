@@ -1,30 +1,306 @@
-//go:build !arm64
-
 package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// LCDConfig is the display at the gate (a character LCD, or an OLED panel) on the I2C bus.
+// One goroutine owns the display and the I2C handle, and everything else sends it messages.
+// Each message has a priority and an optional duration: the highest priority message is shown,
+// and when it runs out the next one underneath comes back (or the idle screen if there are none left).
 type LCDConfig struct {
 	//Config file variables
+	Type           string `json:"type"` //hd44780 (default), ssd1306, or sh1106
 	Bus_num        int    `json:"i2c_bus_number"`
 	Backlight_secs int    `json:"backlight_seconds"`
 	Hex_addr       string `json:"hex_address"`
+	Size           string `json:"size"`            //16x1, 16x2 (default), or 20x4
+	ScrollMillis   int    `json:"scroll_millisec"` //time between steps when a long line scrolls
+	//Internal variables
+	cols       int                   `json:"-"`
+	rows       int                   `json:"-"`
+	idleScreen func() (string, bool) `json:"-"` //only used on the display goroutine
+	queue      chan lcdCommand       `json:"-"`
+	done       chan struct{}         `json:"-"`
+	stopped    chan struct{}         `json:"-"` //display goroutine finished
+	text       string                `json:"-"` //current text (for the simulator page)
+	icon       string                `json:"-"`
+	backlight  bool                  `json:"-"`
+	locker     sync.Mutex            `json:"-"`
+}
+
+// LCD message priorities (the idle screen is below all of these)
+const (
+	LCDPriority_Prompt = 1 //keypad prompts and the PIN being typed
+	LCDPriority_Status = 2 //gate events (welcome, hold open, invalid card)
+)
+
+type lcdCommand struct {
+	text     string //blank removes the message at this priority
+	icon     string
+	priority int
+	duration time.Duration //0 = until it is replaced or removed
+	clear    bool          //remove every message (back to the idle screen)
+	refresh  bool          //check the idle screen again
+}
+
+type lcdItem struct {
+	text    string
+	icon    string
+	expires time.Time //zero = never
 }
 
-func (L *LCDConfig) Setup() (err error) {
-	return
+func (L *LCDConfig) Setup(idle func() (string, bool)) error {
+	if err := L.setupGeometry(); err != nil {
+		return err
+	}
+	haddr, err := strconv.ParseUint(strings.TrimPrefix(L.Hex_addr, "0x"), 16, 8)
+	if err != nil {
+		return err
+	}
+	bus, err := openLCDBus(L.Bus_num, uint8(haddr))
+	if err != nil {
+		fmt.Println("I2C LCD not configured correctly:", err)
+		return err
+	}
+	panel, err := L.newDisplay(bus)
+	if err != nil {
+		fmt.Println("I2C LCD not configured correctly:", err)
+		bus.Close()
+		return err
+	}
+	L.idleScreen = idle
+	L.queue = make(chan lcdCommand, 32)
+	L.done = make(chan struct{})
+	L.stopped = make(chan struct{})
+	go L.run(panel)
+	return nil
 }
 
-func (L *LCDConfig) Display(text string) {
-	fmt.Println("Putting Text on LCD Display:", text)
+// Show puts the text on the LCD for the number of seconds (0 = until replaced)
+// "\n" starts a new line on displays with more than one line
+func (L *LCDConfig) Show(text string, priority int, seconds int) {
+	L.ShowIcon("", text, priority, seconds)
 }
 
+// ShowIcon is Show with an icon next to the text (on displays which can show one)
+func (L *LCDConfig) ShowIcon(icon string, text string, priority int, seconds int) {
+	if text != "" {
+		fmt.Println("Putting Text on LCD Display:", text)
+	}
+	L.send(lcdCommand{text: text, icon: icon, priority: priority, duration: time.Duration(seconds) * time.Second})
+}
+
+// Remove takes down the message at the priority (whatever is underneath it comes back)
+func (L *LCDConfig) Remove(priority int) {
+	L.send(lcdCommand{priority: priority})
+}
+
+// Clear removes every message and goes back to the idle screen (blank if nothing is scheduled)
 func (L *LCDConfig) Clear() {
+	L.send(lcdCommand{clear: true})
+}
 
+func (L *LCDConfig) refreshIdle() {
+	L.send(lcdCommand{refresh: true})
+}
+
+func (L *LCDConfig) send(cmd lcdCommand) {
+	if L == nil || L.queue == nil {
+		return
+	}
+	select {
+	case L.queue <- cmd:
+	case <-L.done:
+	}
 }
 
 func (L *LCDConfig) Close() {
+	if L == nil || L.done == nil {
+		return
+	}
+	close(L.done)
+	<-L.stopped //the display gets cleared on the way out
+}
+
+// Text returns what is currently shown on the LCD
+func (L *LCDConfig) Text() string {
+	if L == nil {
+		return ""
+	}
+	L.locker.Lock()
+	defer L.locker.Unlock()
+	return L.text
+}
+
+// Icon returns the icon currently shown (blank if none)
+func (L *LCDConfig) Icon() string {
+	if L == nil {
+		return ""
+	}
+	L.locker.Lock()
+	defer L.locker.Unlock()
+	return L.icon
+}
+
+func (L *LCDConfig) BacklightOn() bool {
+	if L == nil {
+		return false
+	}
+	L.locker.Lock()
+	defer L.locker.Unlock()
+	return L.backlight
+}
+
+func (L *LCDConfig) setState(text string, icon string, backlight bool) {
+	L.locker.Lock()
+	defer L.locker.Unlock()
+	L.text = text
+	L.icon = icon
+	L.backlight = backlight
+}
+
+// run is the only goroutine which touches the display
+func (L *LCDConfig) run(panel Display) {
+	defer close(L.stopped)
+	items := make(map[int]lcdItem)
+	var lines []string   //what should be on the display (long lines get scrolled)
+	var written []string //what is on the display right now
+	step := 0            //scroll position
+	icon := ""
+	lit := false
+	keepLit := false         //idle screen wants the backlight on
+	var lightUntil time.Time //backlight stays on after something changes
+	expire := time.NewTimer(time.Hour)
+	stopTimer(expire)
+	scroll := time.NewTimer(time.Hour)
+	stopTimer(scroll)
+	light := time.NewTimer(time.Hour)
+	stopTimer(light)
+	idleTick := time.NewTicker(lcdIdleRefresh)
+	defer idleTick.Stop()
+
+	draw := func() {
+		for i := 0; i < L.rows; i++ {
+			line := ""
+			if i < len(lines) {
+				line = scrollLine(lines[i], L.cols, step)
+			}
+			if i < len(written) && written[i] == line {
+				continue //only write the lines which changed
+			}
+			if err := panel.WriteLine(i, line); err != nil {
+				fmt.Println("Error writing to LCD:", err)
+				written = nil //write everything next time
+				return
+			}
+			for len(written) <= i {
+				written = append(written, "")
+			}
+			written[i] = line
+		}
+	}
+	update := func() {
+		now := time.Now()
+		//Drop the messages which have run out, and find the next one to run out
+		var next time.Time
+		top := 0
+		for p, item := range items {
+			if !item.expires.IsZero() && !now.Before(item.expires) {
+				delete(items, p)
+				continue
+			}
+			if !item.expires.IsZero() && (next.IsZero() || item.expires.Before(next)) {
+				next = item.expires
+			}
+			if p > top {
+				top = p
+			}
+		}
+		if next.IsZero() {
+			stopTimer(expire)
+		} else {
+			resetTimer(expire, next.Sub(now))
+		}
+		text, showIcon := "", ""
+		keepLit = false
+		if top > 0 {
+			text, showIcon = items[top].text, items[top].icon
+		} else {
+			text, keepLit = L.idleText()
+		}
+		if show := layoutLCD(text, L.cols, L.rows); strings.Join(show, "\n") != strings.Join(lines, "\n") {
+			lines = show
+			step = 0
+			draw()
+			stopTimer(scroll)
+			if lcdNeedsScroll(lines, L.cols) {
+				resetTimer(scroll, time.Duration(L.scrollMillis())*time.Millisecond)
+			}
+		}
+		if showIcon != icon {
+			if err := panel.SetIcon(showIcon); err != nil {
+				fmt.Println("Error showing LCD icon:", err)
+			}
+			icon = showIcon
+		}
+		//Backlight
+		want := keepLit || now.Before(lightUntil)
+		if want != lit {
+			if err := panel.SetBacklight(want); err != nil {
+				fmt.Println("Error setting LCD backlight:", err)
+			}
+			lit = want
+		}
+		if lit && !keepLit {
+			resetTimer(light, lightUntil.Sub(now))
+		}
+		L.setState(strings.Join(lines, "\n"), icon, lit)
+	}
 
+	panel.Clear()
+	panel.SetBacklight(false)
+	update()
+	for {
+		select {
+		case <-L.done:
+			panel.Clear()
+			panel.SetBacklight(false)
+			panel.Close()
+			return
+		case cmd := <-L.queue:
+			switch {
+			case cmd.refresh:
+			case cmd.clear:
+				items = make(map[int]lcdItem)
+			case cmd.text == "":
+				delete(items, cmd.priority)
+			default:
+				item := lcdItem{text: cmd.text, icon: cmd.icon}
+				if cmd.duration > 0 {
+					item.expires = time.Now().Add(cmd.duration)
+				}
+				items[cmd.priority] = item
+				//Turn on the backlight since something changed on the screen
+				lightUntil = time.Now().Add(time.Duration(L.Backlight_secs) * time.Second)
+			}
+			update()
+		case <-expire.C:
+			update()
+		case <-light.C:
+			update()
+		case <-idleTick.C:
+			if len(items) == 0 {
+				update() //new schedules and the clock
+			}
+		case <-scroll.C:
+			step++
+			draw()
+			resetTimer(scroll, time.Duration(L.scrollMillis())*time.Millisecond)
+		}
+	}
 }
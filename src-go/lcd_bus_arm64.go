@@ -0,0 +1,12 @@
+//go:build arm64
+
+package main
+
+import (
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// openLCDBus connects to the display on the Pi's I2C bus (kept open for as long as the service runs)
+func openLCDBus(bus int, addr uint8) (lcdBus, error) {
+	return i2c.NewI2C(addr, bus)
+}
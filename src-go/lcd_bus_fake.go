@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+)
+
+// Most recent bytes kept by the fake bus
+const fakeLCDBusKeep = 4096
+
+// fakeLCDBus stands in for the I2C bus when there is no real display (simulator builds and tests).
+// It keeps the last bytes written so the output of the display driver can be checked.
+type fakeLCDBus struct {
+	locker  sync.Mutex
+	written []byte
+	closed  bool
+}
+
+func (B *fakeLCDBus) WriteBytes(buf []byte) (int, error) {
+	B.locker.Lock()
+	defer B.locker.Unlock()
+	B.written = append(B.written, buf...)
+	if len(B.written) > fakeLCDBusKeep {
+		B.written = B.written[len(B.written)-fakeLCDBusKeep:]
+	}
+	return len(buf), nil
+}
+
+func (B *fakeLCDBus) Close() error {
+	B.locker.Lock()
+	defer B.locker.Unlock()
+	B.closed = true
+	return nil
+}
+
+// Written returns a copy of the bytes written so far
+func (B *fakeLCDBus) Written() []byte {
+	B.locker.Lock()
+	defer B.locker.Unlock()
+	return append([]byte{}, B.written...)
+}
@@ -0,0 +1,8 @@
+//go:build !arm64
+
+package main
+
+// There is no I2C display off the Pi - the fake bus keeps the simulator running through the same driver
+func openLCDBus(bus int, addr uint8) (lcdBus, error) {
+	return &fakeLCDBus{}, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"time"
+)
+
+// hd44780 drives a character LCD through a PCF8574 I2C backpack, in 4-bit mode.
+// Backpack pins: P0 = RS, P1 = RW, P2 = EN, P3 = backlight, P4-P7 = D4-D7
+type hd44780 struct {
+	bus       lcdBus
+	cols      int
+	rows      int
+	backlight byte
+}
+
+const (
+	pcfRS        = 0x01
+	pcfEN        = 0x04
+	pcfBacklight = 0x08
+)
+
+// Display memory address for the start of each line
+var hd44780RowOffsets = []byte{0x00, 0x40, 0x14, 0x54}
+
+func newHD44780(bus lcdBus, cols int, rows int) (*hd44780, error) {
+	D := &hd44780{bus: bus, cols: cols, rows: rows}
+	time.Sleep(50 * time.Millisecond) //power-on time
+	// The controller might be in 8-bit or 4-bit mode - this sequence gets it into 4-bit mode from either
+	for _, n := range []byte{0x30, 0x30, 0x30, 0x20} {
+		if err := D.writeNibble(n, 0); err != nil {
+			return nil, err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, cmd := range []byte{
+		0x28, //4-bit, 2 lines (16x1 displays use this mode too), 5x8 font
+		0x08, //display off
+		0x06, //move right after each character, no display shift
+		0x0C, //display on, no cursor
+	} {
+		if err := D.command(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return D, D.Clear()
+}
+
+func (D *hd44780) writeNibble(n byte, mode byte) error {
+	b := n&0xF0 | mode | D.backlight
+	_, err := D.bus.WriteBytes([]byte{b | pcfEN, b}) //the data is latched when EN drops
+	return err
+}
+
+func (D *hd44780) write(b byte, mode byte) error {
+	if err := D.writeNibble(b, mode); err != nil {
+		return err
+	}
+	return D.writeNibble(b<<4, mode)
+}
+
+func (D *hd44780) command(cmd byte) error {
+	return D.write(cmd, 0)
+}
+
+func (D *hd44780) Clear() error {
+	err := D.command(0x01)
+	time.Sleep(2 * time.Millisecond) //clear is the slowest command
+	return err
+}
+
+// WriteLine replaces one line of the display (padded with spaces)
+func (D *hd44780) WriteLine(row int, text string) error {
+	if row < 0 || row >= D.rows || row >= len(hd44780RowOffsets) {
+		return nil
+	}
+	if err := D.command(0x80 | hd44780RowOffsets[row]); err != nil {
+		return err
+	}
+	chars := []rune(text)
+	for i := 0; i < D.cols; i++ {
+		c := byte(' ')
+		if i < len(chars) {
+			c = hd44780Char(chars[i])
+		}
+		if err := D.write(c, pcfRS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hd44780Char maps the character onto the display's built-in font (plain ASCII only)
+func hd44780Char(r rune) byte {
+	if c, ok := hd44780Accents[r]; ok {
+		return c
+	}
+	if r < 0x20 || r > 0x7D {
+		return '?'
+	}
+	return byte(r)
+}
+
+// hd44780Accents maps the accented letters (for the translated messages) onto the usual A00 character ROM
+var hd44780Accents = map[rune]byte{
+	'á': 'a', 'é': 'e', 'í': 'i', 'ó': 'o', 'ú': 'u',
+	'Á': 'A', 'É': 'E', 'Í': 'I', 'Ó': 'O', 'Ú': 'U', 'Ñ': 'N',
+	'ñ': 0xEE, 'ä': 0xE1, 'ö': 0xEF, 'ü': 0xF5,
+	'¡': '!', '¿': '?',
+}
+
+// SetIcon does nothing - the built-in font has no room for icons
+func (D *hd44780) SetIcon(icon string) error {
+	return nil
+}
+
+func (D *hd44780) SetBacklight(on bool) error {
+	D.backlight = 0
+	if on {
+		D.backlight = pcfBacklight
+	}
+	_, err := D.bus.WriteBytes([]byte{D.backlight})
+	return err
+}
+
+func (D *hd44780) Close() error {
+	return D.bus.Close()
+}
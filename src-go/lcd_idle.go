@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+)
+
+// How often the idle screen is checked (new schedules and the clock)
+const lcdIdleRefresh = 20 * time.Second
+
+// IdleScreen returns the scheduled message for the gate LCD right now (blank if nothing is scheduled)
+// and whether the backlight should stay on for it
+func IdleScreen(gate string) (string, bool) {
+	list, err := DB.LCDMessageSelectAll(0, true)
+	if err != nil {
+		return "", false
+	}
+	for _, M := range list {
+		if M.AppliesTo(gate) && M.ShowNow() {
+			return M.Render(time.Now()), M.BacklightOn
+		}
+	}
+	return "", false
+}
+
+// RefreshIdleScreens shows any changes to the scheduled messages right away (instead of at the next check)
+func RefreshIdleScreens() {
+	for _, G := range CONFIG.Gates {
+		if G.LCD != nil {
+			G.LCD.refreshIdle()
+		}
+	}
+}
+
+// idleText returns the idle screen (blank if there is none)
+func (L *LCDConfig) idleText() (string, bool) {
+	if L.idleScreen == nil {
+		return "", false
+	}
+	return L.idleScreen()
+}
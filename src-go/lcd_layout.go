@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported LCD sizes: "columns x rows"
+var lcdSizes = map[string][2]int{
+	"16x1": {16, 1},
+	"16x2": {16, 2},
+	"20x4": {20, 4},
+}
+
+const defaultLCDSize = "16x2"
+
+// Default time between each step of a scrolling line
+const defaultLCDScrollMillis = 400
+
+// Blank space between the end of a scrolling line and the start coming around again
+const lcdScrollGap = "    "
+
+func (L *LCDConfig) setupGeometry() error {
+	if L.Size == "" {
+		L.Size = defaultLCDSize
+	}
+	size, ok := lcdSizes[L.Size]
+	if !ok {
+		return fmt.Errorf("unsupported LCD size: %s", L.Size)
+	}
+	L.cols, L.rows = size[0], size[1]
+	return nil
+}
+
+// Rows returns the number of lines on the LCD (0 if there is no LCD)
+func (L *LCDConfig) Rows() int {
+	if L == nil {
+		return 0
+	}
+	return L.rows
+}
+
+func (L *LCDConfig) scrollMillis() int {
+	if L.ScrollMillis <= 0 {
+		return defaultLCDScrollMillis
+	}
+	return L.ScrollMillis
+}
+
+// layoutLCD splits the text into the lines for the display
+// "\n" starts a new line, and a single long line is word-wrapped if the display has room for it.
+// Lines which are still too long are scrolled when shown.
+func layoutLCD(text string, cols int, rows int) []string {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 && rows > 1 && len([]rune(text)) > cols {
+		if wrapped := wrapWords(text, cols); len(wrapped) <= rows {
+			lines = wrapped
+		}
+	}
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	return lines
+}
+
+// wrapWords splits the text on spaces into lines no longer than cols (when the words allow it)
+func wrapWords(text string, cols int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > cols {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// lcdNeedsScroll returns true if any of the lines is too long for the display
+func lcdNeedsScroll(lines []string, cols int) bool {
+	for _, line := range lines {
+		if len([]rune(line)) > cols {
+			return true
+		}
+	}
+	return false
+}
+
+// scrollLine returns the part of the line to show for the scroll step (lines which fit never move)
+func scrollLine(line string, cols int, step int) string {
+	r := []rune(line)
+	if len(r) <= cols {
+		return line
+	}
+	loop := append(r, []rune(lcdScrollGap)...)
+	start := step % len(loop)
+	loop = append(loop, loop...)
+	return string(loop[start : start+cols])
+}
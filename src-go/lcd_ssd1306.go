@@ -0,0 +1,154 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ssd1306 drives a 128x64 OLED (SSD1306 or SH1106 controller) over I2C.
+// Text uses a 7x13 font on 16 pixel lines, and the right-hand 16 pixels are kept for the icon.
+type ssd1306 struct {
+	bus    lcdBus
+	sh1106 bool        //SH1106 memory is 132 columns wide with the screen in the middle
+	screen *image.Gray //what should be on the panel
+}
+
+const (
+	oledWidth      = 128
+	oledHeight     = 64
+	oledLineHeight = 16
+	oledCharWidth  = 7
+	oledIconSize   = 16
+	oledCols       = (oledWidth - oledIconSize) / oledCharWidth
+	oledRows       = oledHeight / oledLineHeight
+	oledBright     = 0xCF //contrast with the "backlight" on
+	oledDim        = 0x08 //contrast with the "backlight" off (still readable, saves the panel)
+)
+
+// Icons are 16x16, one row per number (left-most pixel is the top bit)
+var oledIcons = map[string][16]uint16{
+	LCDIcon_Locked:  {0x07E0, 0x0C30, 0x1818, 0x1008, 0x1008, 0x1008, 0x7FFE, 0x7FFE, 0x7E7E, 0x7C3E, 0x7C3E, 0x7E7E, 0x7E7E, 0x7FFE, 0x7FFE, 0x0000},
+	LCDIcon_Welcome: {0x0000, 0x0003, 0x0007, 0x000E, 0x001C, 0x0038, 0xC070, 0xE0E0, 0x71C0, 0x3B80, 0x1F00, 0x0E00, 0x0400, 0x0000, 0x0000, 0x0000},
+	LCDIcon_Error:   {0x0000, 0x6006, 0x700E, 0x381C, 0x1C38, 0x0E70, 0x07E0, 0x03C0, 0x03C0, 0x07E0, 0x0E70, 0x1C38, 0x381C, 0x700E, 0x6006, 0x0000},
+}
+
+func newSSD1306(bus lcdBus, sh1106 bool) (*ssd1306, error) {
+	D := &ssd1306{bus: bus, sh1106: sh1106, screen: image.NewGray(image.Rect(0, 0, oledWidth, oledHeight))}
+	err := D.command(
+		0xAE,       //display off
+		0xD5, 0x80, //clock
+		0xA8, 0x3F, //64 lines
+		0xD3, 0x00, //no offset
+		0x40,       //start at line 0
+		0x8D, 0x14, //charge pump on (SSD1306)
+		0xAD, 0x8B, //DC-DC on (SH1106 - the SSD1306 ignores it)
+		0x20, 0x02, //page addressing (works the same on both)
+		0xA1,       //flip horizontally
+		0xC8,       //flip vertically (pins at the top)
+		0xDA, 0x12, //COM pins
+		0x81, oledBright,
+		0xD9, 0xF1, //pre-charge
+		0xDB, 0x40, //VCOMH
+		0xA4, //show the memory
+		0xA6, //not inverted
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = D.Clear(); err != nil {
+		return nil, err
+	}
+	return D, D.command(0xAF) //display on
+}
+
+// command sends control bytes (control byte 0x00 first)
+func (D *ssd1306) command(cmds ...byte) error {
+	_, err := D.bus.WriteBytes(append([]byte{0x00}, cmds...))
+	return err
+}
+
+// flush copies the pages (8 pixel rows each) from the screen to the panel
+func (D *ssd1306) flush(firstPage int, lastPage int) error {
+	col := 0
+	if D.sh1106 {
+		col = 2
+	}
+	for page := firstPage; page <= lastPage; page++ {
+		if err := D.command(0xB0|byte(page), byte(col&0x0F), 0x10|byte(col>>4)); err != nil {
+			return err
+		}
+		data := make([]byte, oledWidth+1)
+		data[0] = 0x40 //display data follows
+		for x := 0; x < oledWidth; x++ {
+			var b byte
+			for bit := 0; bit < 8; bit++ {
+				if D.screen.GrayAt(x, page*8+bit).Y > 0x7F {
+					b |= 1 << bit
+				}
+			}
+			data[x+1] = b
+		}
+		if _, err := D.bus.WriteBytes(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (D *ssd1306) fill(r image.Rectangle) {
+	draw.Draw(D.screen, r, image.Black, image.Point{}, draw.Src)
+}
+
+func (D *ssd1306) Clear() error {
+	D.fill(D.screen.Bounds())
+	return D.flush(0, oledHeight/8-1)
+}
+
+func (D *ssd1306) WriteLine(row int, text string) error {
+	if row < 0 || row >= oledRows {
+		return nil
+	}
+	top := row * oledLineHeight
+	D.fill(image.Rect(0, top, oledWidth-oledIconSize, top+oledLineHeight))
+	drawer := font.Drawer{
+		Dst:  D.screen,
+		Src:  image.White,
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(0, top+basicfont.Face7x13.Ascent+1),
+	}
+	drawer.DrawString(text)
+	return D.flush(top/8, (top+oledLineHeight)/8-1)
+}
+
+// SetIcon draws the icon in the top-right corner
+func (D *ssd1306) SetIcon(icon string) error {
+	area := image.Rect(oledWidth-oledIconSize, 0, oledWidth, oledIconSize)
+	D.fill(area)
+	if rows, ok := oledIcons[icon]; ok {
+		for y, bits := range rows {
+			for x := 0; x < oledIconSize; x++ {
+				if bits&(0x8000>>x) != 0 {
+					D.screen.SetGray(area.Min.X+x, y, color.Gray{Y: 0xFF})
+				}
+			}
+		}
+	}
+	return D.flush(0, oledIconSize/8-1)
+}
+
+func (D *ssd1306) SetBacklight(on bool) error {
+	if on {
+		return D.command(0x81, oledBright)
+	}
+	return D.command(0x81, oledDim)
+}
+
+func (D *ssd1306) Close() error {
+	D.command(0xAE) //display off
+	return D.bus.Close()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// hd44780Sent turns the bytes written to the PCF8574 backpack back into the bytes the controller got
+// (RS tells the characters apart from the commands)
+func hd44780Sent(t *testing.T, out []byte) (sent []byte, rs []bool) {
+	t.Helper()
+	if len(out)%4 != 0 {
+		t.Fatalf("%d bytes written - not whole nibble pairs", len(out))
+	}
+	for i := 0; i < len(out); i += 4 {
+		var b byte
+		for n, pulse := range [][]byte{out[i : i+2], out[i+2 : i+4]} {
+			if pulse[0]&pcfEN == 0 || pulse[1] != pulse[0]&^pcfEN {
+				t.Fatalf("byte %d: nibble not latched with EN (%#x %#x)", i, pulse[0], pulse[1])
+			}
+			if pulse[1]&pcfBacklight == 0 {
+				t.Errorf("byte %d: backlight turned off while writing", i)
+			}
+			b |= (pulse[1] & 0xF0) >> (4 * n)
+		}
+		sent = append(sent, b)
+		rs = append(rs, out[i+1]&pcfRS != 0)
+	}
+	return sent, rs
+}
+
+func TestHD44780WriteLine(t *testing.T) {
+	bus := &fakeLCDBus{}
+	D, err := newHD44780(bus, 16, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := D.SetBacklight(true); err != nil {
+		t.Fatal(err)
+	}
+	start := len(bus.Written())
+	if err := D.WriteLine(1, "¡Hola señor!"); err != nil {
+		t.Fatal(err)
+	}
+	sent, rs := hd44780Sent(t, bus.Written()[start:])
+	if len(sent) != 17 || sent[0] != 0x80|0x40 || rs[0] {
+		t.Fatalf("expected the command for line 2 and then 16 characters, got % x", sent)
+	}
+	want := []byte("!Hola se\xEEor!    ")
+	if !bytes.Equal(sent[1:], want) {
+		t.Errorf("characters sent: %q, want %q", sent[1:], want)
+	}
+	for i, data := range rs[1:] {
+		if !data {
+			t.Errorf("character %d sent as a command", i)
+		}
+	}
+
+	// Lines the display does not have are ignored
+	start = len(bus.Written())
+	if err := D.WriteLine(2, "nope"); err != nil || len(bus.Written()) != start {
+		t.Errorf("writing a missing line sent %d bytes (err %v)", len(bus.Written())-start, err)
+	}
+	if err := D.SetBacklight(false); err != nil {
+		t.Fatal(err)
+	}
+	if out := bus.Written(); out[len(out)-1] != 0 {
+		t.Errorf("backlight off wrote %#x", out[len(out)-1])
+	}
+	D.Close()
+	if !bus.closed {
+		t.Error("Close did not close the bus")
+	}
+}
+
+// ssd1306Writes splits the bus output into the separate writes (each one is a command or a page of data)
+func ssd1306Writes(out []byte) [][]byte {
+	var writes [][]byte
+	for len(out) > 0 {
+		n := 4 //page address command: control byte + 3 commands
+		if out[0] == 0x40 {
+			n = oledWidth + 1
+		}
+		if n > len(out) {
+			n = len(out)
+		}
+		writes = append(writes, out[:n])
+		out = out[n:]
+	}
+	return writes
+}
+
+func TestSSD1306WriteLine(t *testing.T) {
+	for _, sh1106 := range []bool{false, true} {
+		bus := &fakeLCDBus{}
+		D, err := newSSD1306(bus, sh1106)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := len(bus.Written())
+		if err := D.WriteLine(1, "I"); err != nil {
+			t.Fatal(err)
+		}
+		writes := ssd1306Writes(bus.Written()[start:])
+		if len(writes) != 4 {
+			t.Fatalf("sh1106=%v: line 2 took %d writes, want 2 pages (address + data each)", sh1106, len(writes))
+		}
+		col := byte(0)
+		if sh1106 {
+			col = 2
+		}
+		for i, page := range []byte{2, 3} {
+			if want := []byte{0x00, 0xB0 | page, col, 0x10}; !bytes.Equal(writes[i*2], want) {
+				t.Errorf("sh1106=%v: page address % x, want % x", sh1106, writes[i*2], want)
+			}
+		}
+		// The "I" is in the first character cell, and nothing else on the line is lit
+		lit := 0
+		for _, data := range [][]byte{writes[1], writes[3]} {
+			for x, b := range data[1:] {
+				if b != 0 && x >= oledCharWidth {
+					t.Errorf("sh1106=%v: pixels lit in column %d", sh1106, x)
+				}
+				for ; b != 0; b &= b - 1 {
+					lit++
+				}
+			}
+		}
+		if lit == 0 {
+			t.Errorf("sh1106=%v: no pixels lit for the text", sh1106)
+		}
+
+		if err := D.SetBacklight(false); err != nil {
+			t.Fatal(err)
+		}
+		if out := bus.Written(); !bytes.HasSuffix(out, []byte{0x00, 0x81, oledDim}) {
+			t.Errorf("sh1106=%v: dimming wrote % x", sh1106, out[len(out)-3:])
+		}
+	}
+}
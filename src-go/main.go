@@ -34,10 +34,60 @@ type Page struct {
 	GateLog      *GateLog
 	Contacts     []Contact
 	Contact      *Contact
+	Sims         []SimStatus
+	Holds        []GateHold
+	Hold         GateHold
+	LCDMessages  []LCDMessage
+	LCDMessage   LCDMessage
+	Gates        []*GateConfig
+	Lockouts     []KeypadLockout
+	Directory    []DirectoryEntry
+	Entry        DirectoryEntry
+	Visitor      *VisitorCall
+	VisitorToken string
+}
+
+// Simulator is used by the templates to show the simulator page
+func (p *Page) Simulator() bool {
+	return SimulatorEnabled()
+}
+
+// LockoutConfig is used by the templates to show the keypad lockout settings
+func (p *Page) LockoutConfig() LockoutConfig {
+	return CONFIG.Lockout
+}
+
+// MultiGate is used by the templates to only show gate choices when there is more than one gate
+func (p *Page) MultiGate() bool {
+	return len(CONFIG.Gates) > 1
+}
+
+// CardReaders is true when any gate has a card/fob reader
+func (p *Page) CardReaders() bool {
+	for _, G := range CONFIG.Gates {
+		if G.Wiegand != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PinLengths is used by the templates to list the PIN lengths allowed on the keypads
+func (p *Page) PinLengths() []int {
+	var list []int
+	for n := CONFIG.PinEntry.minLength(); n <= CONFIG.PinEntry.maxLength(); n++ {
+		list = append(list, n)
+	}
+	return list
+}
+
+// Languages is used by the templates to list the languages an account can pick
+func (p *Page) Languages() []Language {
+	return Languages()
 }
 
 var templates *template.Template
-var CAM *Camera
+var GPIO GPIOBackend
 var DB *Database
 var CONFIG *Config
 
@@ -65,31 +115,23 @@ func main() {
 	templates, err = template.ParseFS(htmlFS, "html/*.html")
 	exitErr(err, "Could not load Templates: %v")
 
-	//Setup the Camera
-	CAM, err = NewCamera(CONFIG.Camera)
-	if err != nil {
-		fmt.Println(err)
-	}
-	//exitErr(err, "Could not create Camera: %v")
-	defer CAM.Close()
-
 	//Setup the Database
 	DB, err = NewDatabase(CONFIG.DbFile)
 	exitErr(err, "Could not create database: %v")
 	defer DB.Close()
 
-	// Setup the Gate
-	err = CONFIG.Gate.SetupGate()
-	exitErr(err, "Could not setup Gate (check settings): %v")
+	// Setup the GPIO backend
+	GPIO, err = CONFIG.GPIO.NewBackend()
+	exitErr(err, "Could not setup GPIO backend (check settings): %v")
+	defer GPIO.Close()
 
-	// Setup the LCD
-	err = CONFIG.LCD.Setup()
-	exitErr(err, "Could not setup I2C LCD (check settings): %v")
-	defer CONFIG.LCD.Close()
-
-	// Setup the Keypad watcher
-	CONFIG.Keypad.StartWatching()
-	defer CONFIG.Keypad.Close()
+	// Setup the Gates (relay, camera, LCD, and keypad for each one)
+	for _, G := range CONFIG.Gates {
+		err = G.Setup()
+		exitErr(err, "Could not setup Gate "+G.Name+" (check settings): %v")
+		defer G.Close()
+	}
+	SetupSimulator()
 
 	//Setup the HTTP auth system
 	setupSecureCookies()
@@ -97,12 +139,13 @@ func main() {
 	//Setup the pages / endpoints
 	http.HandleFunc("/favicon.ico", favicon)
 	http.Handle("/static/", http.StripPrefix("/", http.FileServer(http.FS(staticFS))))
-	http.HandleFunc("/stream", checkToken(CAM.ServeImages, true, false))
+	http.HandleFunc("/stream", checkToken(serveGateStream, true, false))
 
 	// Individual Pages
 	setupPages()
 	// Final setup
 	go DB.PruneTables() //Runs the pruning checks every day
+	go RunHoldScheduler()
 
 	http.HandleFunc("/", handleError)
 	fmt.Println("Listening on port" + CONFIG.Host)
@@ -136,6 +179,7 @@ func checkToken(fn func(http.ResponseWriter, *http.Request, *Page), validateToke
 	return func(w http.ResponseWriter, r *http.Request) {
 		p := &Page{
 			Title: CONFIG.SiteName,
+			Gates: CONFIG.Gates,
 		}
 		if validateToken {
 			toks := ReadSecureCookieTokens(w, r)
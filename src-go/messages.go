@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Message catalog for the text shown on the LCDs and sent out in notifications/emails.
+// The LCDs use the site language (except the welcome after a PIN, which greets the account holder
+// in their own language), and notifications/emails use the language picked for the account
+// (or the site language if the account has not picked one).
+// Any message can be reworded (or a whole new language added) with the "messages" section of the config file.
+
+const defaultLanguage = "en"
+
+// Message keys
+const (
+	// LCD text
+	Msg_Welcome       = "lcd_welcome"
+	Msg_InvalidPIN    = "lcd_invalid_pin"
+	Msg_InvalidCard   = "lcd_invalid_card"
+	Msg_PinNeeded     = "lcd_pin_needed"
+	Msg_KeypadLocked  = "lcd_keypad_locked"
+	Msg_EnterPIN      = "lcd_enter_pin"  //%s = enter key
+	Msg_PinDigits     = "lcd_pin_digits" //%d = digits typed so far
+	Msg_UnitThenCall  = "lcd_unit_then"  //%s = call key
+	Msg_PleaseWait    = "lcd_please_wait"
+	Msg_Calling       = "lcd_calling" //%s = directory label
+	Msg_UnknownUnit   = "lcd_unknown_unit"
+	Msg_NoAnswer      = "lcd_no_answer"
+	Msg_AdminMenu     = "lcd_admin_menu"
+	Msg_AdminName     = "lcd_admin_name" //%s = first name
+	Msg_Error         = "lcd_error"
+	Msg_GateHeld      = "lcd_gate_held"
+	Msg_GateHoldEnded = "lcd_gate_hold_ended"
+	// Notifications
+	Msg_GateSubject       = "gate_subject"        //%s = site name
+	Msg_GateEntering      = "gate_entering"       //%s = label for the PIN/card
+	Msg_GateSuffix        = "gate_suffix"         //%s = gate name (only added when there is more than one gate)
+	Msg_DuressSubject     = "duress_subject"      //%s = site name
+	Msg_DuressAlert       = "duress_alert"        //%s = label, %s = gate name, %s = opened yes/no
+	Msg_AlertSubject      = "alert_subject"       //%s = site name
+	Msg_AlertLeftOpen     = "alert_left_open"     //%s = gate name, %s = how long
+	Msg_AlertOpenFailed   = "alert_open_failed"   //%s = gate name
+	Msg_AlertForced       = "alert_forced"        //%s = gate name
+	Msg_AlertPresence     = "alert_presence"      //%s = gate name, %s = how long
+	Msg_LockedSubject     = "locked_subject"      //%s = site name
+	Msg_LockedAlert       = "locked_alert"        //%s = gate name, %s = how long, %d = bad PINs
+	Msg_VisitorSubject    = "visitor_subject"     //%s = site name
+	Msg_VisitorCall       = "visitor_call"        //%s = label, %s = where, %d = minutes, %s = link
+	Msg_VisitorCallShort  = "visitor_call_short"  //%s = link
+	Msg_VisitorWhereGate  = "visitor_where"       //the gate (single gate)
+	Msg_VisitorWhereNamed = "visitor_where_named" //%s = gate name
+	Msg_Yes               = "yes"
+	Msg_No                = "no"
+	// Emails
+	Msg_ResetSubject   = "reset_subject"   //%s = site name
+	Msg_ResetBody      = "reset_body"      //%s = host, %s = temporary password
+	Msg_NewAcctSubject = "newacct_subject" //%s = site name
+	Msg_NewAcctBody    = "newacct_body"    //%s = name, %s = host, %s = temporary password
+	Msg_TestSubject    = "test_subject"
+	Msg_TestBody       = "test_body" //%s = site name
+)
+
+// Names of the languages (for the account settings)
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Español",
+}
+
+var messageCatalog = map[string]map[string]string{
+	"en": {
+		Msg_Welcome:       "Welcome!",
+		Msg_InvalidPIN:    "Invalid PIN",
+		Msg_InvalidCard:   "Invalid Card",
+		Msg_PinNeeded:     "PIN Needed",
+		Msg_KeypadLocked:  "Locked - try later",
+		Msg_EnterPIN:      "Enter PIN then %s",
+		Msg_PinDigits:     "Digits: %d",
+		Msg_UnitThenCall:  "Unit # then %s",
+		Msg_PleaseWait:    "Please wait",
+		Msg_Calling:       "Calling %s",
+		Msg_UnknownUnit:   "Unknown unit",
+		Msg_NoAnswer:      "No answer",
+		Msg_AdminMenu:     "1:Hold 2:Release",
+		Msg_AdminName:     "Admin: %s",
+		Msg_Error:         "Error",
+		Msg_GateHeld:      "Gate Held Open",
+		Msg_GateHoldEnded: "Hold Open Ended",
+
+		Msg_GateSubject:       "%s Gate Notification",
+		Msg_GateEntering:      "%s is entering the neighborhood",
+		Msg_GateSuffix:        " (%s gate)",
+		Msg_DuressSubject:     "URGENT: %s Duress PIN",
+		Msg_DuressAlert:       "Duress PIN for %s entered at the %s gate (gate opened: %s)",
+		Msg_AlertSubject:      "%s Gate Alert",
+		Msg_AlertLeftOpen:     "The %s gate has been open for %s",
+		Msg_AlertOpenFailed:   "The %s gate did not open after being triggered",
+		Msg_AlertForced:       "The %s gate was opened without a PIN or web request",
+		Msg_AlertPresence:     "Someone was at the %s gate, but it was not opened within %s",
+		Msg_LockedSubject:     "%s Keypad Locked",
+		Msg_LockedAlert:       "The %s gate keypad is locked for %s after %d bad PINs",
+		Msg_VisitorSubject:    "%s Visitor at Gate",
+		Msg_VisitorCall:       "Visitor for %s at %s. Open within %d min: %s",
+		Msg_VisitorCallShort:  "Visitor at gate: %s",
+		Msg_VisitorWhereGate:  "gate",
+		Msg_VisitorWhereNamed: "%s gate",
+		Msg_Yes:               "yes",
+		Msg_No:                "no",
+
+		Msg_ResetSubject:   "%s Password Reset",
+		Msg_ResetBody:      "A password reset has been requested for you at %s.\nPlease login and change your password as soon as possible.\n\nYour temporary password is:  %s",
+		Msg_NewAcctSubject: "New %s Account",
+		Msg_NewAcctBody:    "%s has just created an account for you at %s.\nPlease login and change your password as soon as possible.\n\nYour temporary password is:  %s",
+		Msg_TestSubject:    "Notification Test",
+		Msg_TestBody:       "This is a test of the %s notification system",
+	},
+	"es": {
+		Msg_Welcome:       "¡Bienvenido!",
+		Msg_InvalidPIN:    "PIN inválido",
+		Msg_InvalidCard:   "Tarjeta inválida",
+		Msg_PinNeeded:     "Falta el PIN",
+		Msg_KeypadLocked:  "Bloqueado - espere",
+		Msg_EnterPIN:      "PIN y luego %s",
+		Msg_PinDigits:     "Dígitos: %d",
+		Msg_UnitThenCall:  "Unidad y luego %s",
+		Msg_PleaseWait:    "Espere por favor",
+		Msg_Calling:       "Llamando a %s",
+		Msg_UnknownUnit:   "Unidad desconocida",
+		Msg_NoAnswer:      "Sin respuesta",
+		Msg_AdminMenu:     "1:Abrir 2:Cerrar",
+		Msg_AdminName:     "Admin: %s",
+		Msg_Error:         "Error",
+		Msg_GateHeld:      "Puerta abierta",
+		Msg_GateHoldEnded: "Fin de apertura",
+
+		Msg_GateSubject:       "%s - Aviso de la puerta",
+		Msg_GateEntering:      "%s está entrando al vecindario",
+		Msg_GateSuffix:        " (puerta %s)",
+		Msg_DuressSubject:     "URGENTE: %s - PIN de coacción",
+		Msg_DuressAlert:       "Se usó el PIN de coacción de %s en la puerta %s (puerta abierta: %s)",
+		Msg_AlertSubject:      "%s - Alerta de la puerta",
+		Msg_AlertLeftOpen:     "La puerta %s lleva abierta %s",
+		Msg_AlertOpenFailed:   "La puerta %s no se abrió al activarla",
+		Msg_AlertForced:       "La puerta %s se abrió sin PIN ni pedido web",
+		Msg_AlertPresence:     "Hubo alguien en la puerta %s, pero no se abrió en %s",
+		Msg_LockedSubject:     "%s - Teclado bloqueado",
+		Msg_LockedAlert:       "El teclado de la puerta %s está bloqueado por %s después de %d PIN incorrectos",
+		Msg_VisitorSubject:    "%s - Visita en la puerta",
+		Msg_VisitorCall:       "Visita para %s en la %s. Abra en menos de %d min: %s",
+		Msg_VisitorCallShort:  "Visita en la puerta: %s",
+		Msg_VisitorWhereGate:  "puerta",
+		Msg_VisitorWhereNamed: "puerta %s",
+		Msg_Yes:               "sí",
+		Msg_No:                "no",
+
+		Msg_ResetSubject:   "%s - Cambio de contraseña",
+		Msg_ResetBody:      "Se pidió un cambio de contraseña para usted en %s.\nInicie sesión y cambie su contraseña lo antes posible.\n\nSu contraseña temporal es:  %s",
+		Msg_NewAcctSubject: "Nueva cuenta de %s",
+		Msg_NewAcctBody:    "%s le acaba de crear una cuenta en %s.\nInicie sesión y cambie su contraseña lo antes posible.\n\nSu contraseña temporal es:  %s",
+		Msg_TestSubject:    "Prueba de avisos",
+		Msg_TestBody:       "Esta es una prueba del sistema de avisos de %s",
+	},
+}
+
+// Language is one of the choices for the account settings
+type Language struct {
+	Code string
+	Name string
+}
+
+// Message returns the text for the key in the language (filled in with the args)
+// Anything missing from the language falls back to the site language, and then to English.
+func Message(lang string, key string, args ...interface{}) string {
+	format := ""
+	for _, l := range []string{lang, siteLanguage(), defaultLanguage} {
+		if text, ok := lookupMessage(l, key); ok {
+			format = text
+			break
+		}
+	}
+	if format == "" {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// SiteMessage returns the text for the key in the site language (used for the LCDs)
+func SiteMessage(key string, args ...interface{}) string {
+	return Message(siteLanguage(), key, args...)
+}
+
+func lookupMessage(lang string, key string) (string, bool) {
+	if lang == "" {
+		return "", false
+	}
+	if CONFIG != nil {
+		if text, ok := CONFIG.Messages[lang][key]; ok {
+			return text, true
+		}
+	}
+	text, ok := messageCatalog[lang][key]
+	return text, ok
+}
+
+func siteLanguage() string {
+	if CONFIG == nil || CONFIG.Language == "" {
+		return defaultLanguage
+	}
+	return CONFIG.Language
+}
+
+// ValidLanguage returns true for the languages in the catalog (or added in the config file)
+func ValidLanguage(lang string) bool {
+	if _, ok := messageCatalog[lang]; ok {
+		return true
+	}
+	_, ok := CONFIG.Messages[lang]
+	return ok
+}
+
+// Languages lists the languages which can be picked for an account
+func Languages() []Language {
+	var list []Language
+	for code := range messageCatalog {
+		list = append(list, Language{Code: code, Name: languageName(code)})
+	}
+	for code := range CONFIG.Messages {
+		if _, ok := messageCatalog[code]; !ok {
+			list = append(list, Language{Code: code, Name: languageName(code)})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return strings.ToUpper(code)
+}
+
+// yesNo is the translated "yes" or "no" for a flag
+func yesNo(lang string, b bool) string {
+	if b {
+		return Message(lang, Msg_Yes)
+	}
+	return Message(lang, Msg_No)
+}
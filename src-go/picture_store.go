@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+// The gate pictures are kept in a folder next to the database instead of inside it.
+// Each picture is named by the SHA-256 hash of the JPEG (so the same picture is only ever stored once),
+// in a sub-folder for the first two characters of the hash, with a small thumbnail beside it:
+//   pictures/ab/ab12...ef.jpg
+//   pictures/ab/ab12...ef_thumb.jpg
+
+const (
+	thumbnailWidth   = 160
+	thumbnailQuality = 75
+)
+
+func (D *Database) picturesDir() string {
+	return filepath.Join(filepath.Dir(D.filepath), "pictures")
+}
+
+// validPictureHash makes sure the hash can be used as a file name (no path tricks)
+func validPictureHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func (D *Database) picturePath(hash string, thumb bool) string {
+	name := hash + ".jpg"
+	if thumb {
+		name = hash + "_thumb.jpg"
+	}
+	return filepath.Join(D.picturesDir(), hash[:2], name)
+}
+
+// SavePicture puts the JPEG into the picture store (along with a thumbnail) and returns the hash for it
+func (D *Database) SavePicture(picture []byte) (string, error) {
+	sum := sha256.Sum256(picture)
+	hash := hex.EncodeToString(sum[:])
+	path := D.picturePath(hash, false)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil //already have it
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if thumb, err := makeThumbnail(picture); err != nil {
+		fmt.Println("Unable to make picture thumbnail:", err) //the full picture gets used instead
+	} else if thumb != nil {
+		if err := writeFileAtomic(D.picturePath(hash, true), thumb); err != nil {
+			return "", err
+		}
+	}
+	// The full picture goes last - once it is there the picture is complete
+	if err := writeFileAtomic(path, picture); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// makeThumbnail shrinks the picture down (nil if it is already small enough to use as-is)
+func makeThumbnail(picture []byte) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(picture))
+	if err != nil {
+		return nil, err
+	} else if img.Bounds().Dx() <= thumbnailWidth {
+		return nil, nil
+	}
+	buf := new(bytes.Buffer)
+	err = jpeg.Encode(buf, imaging.Resize(img, thumbnailWidth, 0, imaging.Box), &jpeg.Options{Quality: thumbnailQuality})
+	return buf.Bytes(), err
+}
+
+// writeFileAtomic writes to a temporary file first, so nothing ever sees half a picture
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// servePicture sends the picture (or its thumbnail) from the picture store
+func (D *Database) servePicture(w http.ResponseWriter, r *http.Request, hash string, thumb bool) {
+	if !validPictureHash(hash) {
+		http.Error(w, "Picture not found", http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(D.picturePath(hash, thumb))
+	if err != nil && thumb {
+		file, err = os.Open(D.picturePath(hash, false)) //no thumbnail for this one
+	}
+	if err != nil {
+		http.Error(w, "Picture not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Picture not found", http.StatusNotFound)
+		return
+	}
+	// The picture for a hash never changes
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// removePictures deletes the pictures from the store which are not used by any gate log entry anymore
+func (D *Database) removePictures(hashes []string) {
+	for _, hash := range hashes {
+		if !validPictureHash(hash) {
+			continue
+		}
+		if D.pictureUsed(hash) {
+			continue
+		}
+		for _, thumb := range []bool{true, false} {
+			if err := os.Remove(D.picturePath(hash, thumb)); err != nil && !os.IsNotExist(err) {
+				fmt.Println("Error removing gate picture:", err)
+			}
+		}
+	}
+}
+
+// pictureUsed checks if any gate log entry still has the picture (true on errors - better to keep it)
+func (D *Database) pictureUsed(hash string) bool {
+	rows, err := D.QuerySql(`select count(*) from gatelog where picture_hash = ?;`, hash)
+	if err != nil {
+		return true
+	}
+	defer rows.Close()
+	count := 0
+	if rows.Next() && rows.Scan(&count) != nil {
+		return true
+	}
+	return count > 0
+}
+
+// migrateGatePictures moves any pictures still inside the database out to the picture store
+func (D *Database) migrateGatePictures() error {
+	if exists, err := D.hasColumn("gatelog", "gate_picture_bytes"); err != nil || !exists {
+		return err
+	}
+	moved := 0
+	for {
+		rows, err := D.QuerySql(`select log_id, gate_picture_bytes from gatelog where length(gate_picture_bytes) > 0 limit 100;`)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var pictures [][]byte
+		for rows.Next() {
+			var id int64
+			var picture []byte
+			if err := rows.Scan(&id, &picture); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			pictures = append(pictures, picture)
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			break
+		}
+		for i, id := range ids {
+			hash, err := D.SavePicture(pictures[i])
+			if err != nil {
+				return fmt.Errorf("moving gate picture %d: %w", id, err)
+			}
+			if _, err := D.ExecSql(`update gatelog set picture_hash = ?, gate_picture_bytes = null where log_id = ?;`, hash, id); err != nil {
+				return err
+			}
+			moved++
+		}
+	}
+	if moved > 0 {
+		fmt.Printf("Moved %d gate pictures out of the database into %s\n", moved, D.picturesDir())
+		// Give the space back
+		if _, err := D.ExecSql("vacuum;"); err != nil {
+			fmt.Println("Error compacting the database:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testDatabase(t *testing.T) *Database {
+	t.Helper()
+	D, err := NewDatabase(filepath.Join(t.TempDir(), "gatemaster.sqlite"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(D.Close)
+	return D
+}
+
+func pictureHash(picture []byte) string {
+	sum := sha256.Sum256(picture)
+	return hex.EncodeToString(sum[:])
+}
+
+func testPictureFile(t *testing.T, D *Database, hash string, thumb bool) []byte {
+	t.Helper()
+	data, err := os.ReadFile(D.picturePath(hash, thumb))
+	if err != nil {
+		t.Errorf("picture %s (thumbnail %v) not in the store: %v", hash, thumb, err)
+	}
+	return data
+}
+
+// Databases from before the picture store kept the pictures in the gatelog table
+func TestMigrateGatePictures(t *testing.T) {
+	D := testDatabase(t)
+	if _, err := D.ExecSql(`alter table gatelog add column gate_picture_bytes blob;`); err != nil {
+		t.Fatal(err)
+	}
+	large := testJPEG(t, 640, 480, color.Gray{Y: 50})
+	small := testJPEG(t, 100, 75, color.Gray{Y: 150}) //already thumbnail size
+	for _, picture := range [][]byte{large, small, large, nil} {
+		q := `insert into gatelog (opened_name, time_opened, success, gate_picture_bytes) values ('test', ?, true, ?);`
+		if _, err := D.ExecSql(q, D.TimeNow(), picture); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := D.MigrateTables(); err != nil {
+		t.Fatalf("MigrateTables: %v", err)
+	}
+	rows, err := D.QuerySql(`select picture_hash, length(gate_picture_bytes) from gatelog order by log_id;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		var left *int
+		if err := rows.Scan(&hash, &left); err != nil {
+			t.Fatal(err)
+		}
+		if left != nil && *left > 0 {
+			t.Errorf("picture for %q still in the database", hash)
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+	want := []string{pictureHash(large), pictureHash(small), pictureHash(large), ""}
+	for i := range want {
+		if i >= len(hashes) || hashes[i] != want[i] {
+			t.Fatalf("picture hashes after the migration: %q, want %q", hashes, want)
+		}
+	}
+
+	if got := testPictureFile(t, D, hashes[0], false); !bytes.Equal(got, large) {
+		t.Error("picture in the store does not match the one from the database")
+	}
+	thumb := testPictureFile(t, D, hashes[0], true)
+	if width, _ := testPictureSize(t, thumb); width != thumbnailWidth {
+		t.Errorf("thumbnail is %d wide, want %d", width, thumbnailWidth)
+	}
+	if got := testPictureFile(t, D, hashes[1], false); !bytes.Equal(got, small) {
+		t.Error("small picture in the store does not match the one from the database")
+	}
+	if _, err := os.Stat(D.picturePath(hashes[1], true)); !os.IsNotExist(err) {
+		t.Error("small picture got a thumbnail")
+	}
+
+	// Nothing left to move the next time
+	if err := D.MigrateTables(); err != nil {
+		t.Fatalf("MigrateTables again: %v", err)
+	}
+}
+
+func TestPruneGateLogsPictures(t *testing.T) {
+	D := testDatabase(t)
+	shared := testJPEG(t, 320, 240, color.Gray{Y: 80})
+	oldOnly := testJPEG(t, 320, 240, color.Gray{Y: 180})
+	longAgo := time.Now().AddDate(-2, 0, 0)
+	for _, entry := range []struct {
+		picture []byte
+		old     bool
+	}{{shared, true}, {oldOnly, true}, {shared, false}} {
+		gl, err := D.GateLogInsert(&GateLog{OpenedName: "test", GatePicture: entry.picture, Success: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.old {
+			if _, err := D.ExecSql(`update gatelog set time_opened = ? where log_id = ?;`, D.ToTime(longAgo), gl.LogID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := D.PruneGateLogs(time.Now().AddDate(-1, 0, 0)); err != nil {
+		t.Fatalf("PruneGateLogs: %v", err)
+	}
+	logs, err := D.GatelogSelectAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].PictureHash != pictureHash(shared) {
+		t.Fatalf("gate logs after pruning: %+v", logs)
+	}
+	// The newer entry still needs the shared picture
+	testPictureFile(t, D, pictureHash(shared), false)
+	testPictureFile(t, D, pictureHash(shared), true)
+	for _, thumb := range []bool{false, true} {
+		if _, err := os.Stat(D.picturePath(pictureHash(oldOnly), thumb)); !os.IsNotExist(err) {
+			t.Errorf("picture only used by a pruned entry was kept (thumbnail %v)", thumb)
+		}
+	}
+}
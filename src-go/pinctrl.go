@@ -5,6 +5,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type PinState int
@@ -15,7 +16,39 @@ const (
 	PIN_DOWN
 )
 
-func ReadPins(list string, highlow bool) map[int]PinState {
+// How often WatchEdges reads the pins. Every read starts a new pinctrl process, so this is kept
+// as slow as a key press allows (the chardev backend does not need to poll at all).
+const pinctrlPollEvery = 50 * time.Millisecond
+
+// PinctrlGPIO runs the "pinctrl" utility for every pin operation
+type PinctrlGPIO struct {
+	done chan struct{}
+}
+
+func (P *PinctrlGPIO) Close() {
+	if P.done != nil {
+		close(P.done)
+	}
+}
+
+// WatchEdges has to poll the pin levels - pinctrl has no way to wait for a change
+func (P *PinctrlGPIO) WatchEdges(pins []uint32) (<-chan PinEvent, error) {
+	return pollEdges(P.ReadPins, pins, pinctrlPollEvery, P.done), nil
+}
+
+func (P *PinctrlGPIO) ReadPins(pins []uint32) map[uint32]PinState {
+	var list []string
+	for _, pin := range pins {
+		list = append(list, fmt.Sprintf("%d", pin))
+	}
+	state := make(map[uint32]PinState)
+	for pin, stat := range readPinctrl(strings.Join(list, ","), true) {
+		state[uint32(pin)] = stat
+	}
+	return state
+}
+
+func readPinctrl(list string, highlow bool) map[int]PinState {
 	// Input list: "1,2,4,6" or "1,4-8,20" (comma-separated and ranges, no spaces though)
 	//Line format: "<number>: [ip|op] [--] [pu|pd] | [hi|lo] // [Human-Readable name] = [input]"
 	// Example: "2: ip    pu | hi // GPIO2 = input"
@@ -76,7 +109,7 @@ func ReadPins(list string, highlow bool) map[int]PinState {
 }*/
 
 // Primary "set" functions
-func SetPinUp(pin uint32) error {
+func (P *PinctrlGPIO) SetPinUp(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "pu").Run()
 	if err != nil {
 		fmt.Println("Got Error [SetPinUp]", err)
@@ -84,15 +117,15 @@ func SetPinUp(pin uint32) error {
 	return err
 }
 
-func SetPinDown(pin uint32) error {
+func (P *PinctrlGPIO) SetPinDown(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "pd").Run()
 	if err != nil {
-		fmt.Println("Got Error [SetPinUp]", err)
+		fmt.Println("Got Error [SetPinDown]", err)
 	}
 	return err
 }
 
-func SetInput(pin uint32) error {
+func (P *PinctrlGPIO) SetInput(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "ip").Run()
 	if err != nil {
 		fmt.Println("Got Error [SetInput]", err)
@@ -100,7 +133,7 @@ func SetInput(pin uint32) error {
 	return err
 }
 
-func SetOutput(pin uint32) error {
+func (P *PinctrlGPIO) SetOutput(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "op").Run()
 	if err != nil {
 		fmt.Println("Got Error [SetOutput]", err)
@@ -108,7 +141,7 @@ func SetOutput(pin uint32) error {
 	return err
 }
 
-func SetOutputDriveHigh(pin uint32) error {
+func (P *PinctrlGPIO) SetOutputDriveHigh(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "dh").Run()
 	if err != nil {
 		fmt.Println("Got Error [SetOutputDriveHigh]", err)
@@ -116,7 +149,7 @@ func SetOutputDriveHigh(pin uint32) error {
 	return err
 }
 
-func SetOutputDriveLow(pin uint32) error {
+func (P *PinctrlGPIO) SetOutputDriveLow(pin uint32) error {
 	err := exec.Command("pinctrl", "set", fmt.Sprintf("%d", pin), "dl").Run()
 	if err != nil {
 		fmt.Println("Got Error [SetOutputDriveLow]", err)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -84,6 +85,16 @@ func parseFormTime(t string) *time.Time {
 	return &dt
 }
 
+func parseFormDateTime(dt string) *time.Time {
+	// Format from the "datetime-local" input type
+	inittimelocation()
+	t, err := time.ParseInLocation("2006-01-02T15:04", dt, timelocation)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 func parseFormInt(i string) int {
 	//Note: Returns "0" for invalid/blank input strings
 	num, err := strconv.Atoi(i)
@@ -91,4 +102,23 @@ func parseFormInt(i string) int {
 		return 0
 	}
 	return num
-}
\ No newline at end of file
+}
+
+// parseFormGates checks the gate names picked on a form
+// Returns nil when every gate was picked (no restriction)
+func parseFormGates(names []string) ([]string, error) {
+	var gates []string
+	for _, n := range names {
+		if n == "" || CONFIG.GateByName(n) == nil {
+			return nil, fmt.Errorf("unknown gate: %s", n)
+		}
+		gates = append(gates, n)
+	}
+	if len(gates) == 0 {
+		return nil, fmt.Errorf("select at least one gate")
+	}
+	if len(gates) >= len(CONFIG.Gates) {
+		return nil, nil
+	}
+	return gates, nil
+}
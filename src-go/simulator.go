@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Simulator mode is active whenever the GPIO backend is the in-memory simulator.
+// The virtual relay, LCD and keypad can then be driven from the admin web page.
+
+type SimPulse struct {
+	Start  time.Time
+	Length time.Duration
+	Active bool //relay still turned on
+}
+
+type SimStatus struct {
+	Gate        string
+	LCDText     string
+	LCDIcon     string //OLED displays only
+	Backlight   bool
+	RelayActive bool
+	HasSensor   bool
+	HasReader   bool //card/fob reader
+	GateState   string
+	Pulses      []SimPulse //newest first
+	Keys        [][]string
+}
+
+func SimulatorEnabled() bool {
+	_, ok := GPIO.(*SimGPIO)
+	return ok
+}
+
+func SetupSimulator() {
+	sim, ok := GPIO.(*SimGPIO)
+	if !ok {
+		return
+	}
+	fmt.Println("Running in hardware simulator mode")
+	for _, G := range CONFIG.Gates {
+		sim.RecordOutput(G.GpioPin)
+	}
+}
+
+// SimCurrentStatus returns the status of every gate (same order as the config)
+func SimCurrentStatus() []SimStatus {
+	var list []SimStatus
+	for _, G := range CONFIG.Gates {
+		list = append(list, simGateStatus(G))
+	}
+	return list
+}
+
+func simGateStatus(G *GateConfig) SimStatus {
+	S := SimStatus{
+		Gate:      G.Name,
+		LCDText:   G.LCD.Text(),
+		LCDIcon:   G.LCD.Icon(),
+		Backlight: G.LCD.BacklightOn(),
+	}
+	sim, ok := GPIO.(*SimGPIO)
+	if !ok {
+		return S
+	}
+	// Turn the recorded relay pin levels into pulses
+	var current *SimPulse
+	for _, change := range sim.OutputHistory(G.GpioPin) {
+		active := change.High != G.Invert
+		if active && current == nil {
+			current = &SimPulse{Start: change.Time, Active: true}
+		} else if !active && current != nil {
+			current.Length = change.Time.Sub(current.Start)
+			current.Active = false
+			S.Pulses = append([]SimPulse{*current}, S.Pulses...)
+			current = nil
+		}
+	}
+	if current != nil {
+		current.Length = time.Since(current.Start)
+		S.Pulses = append([]SimPulse{*current}, S.Pulses...)
+		S.RelayActive = true
+	}
+	if G.Keypad != nil {
+		S.Keys = G.Keypad.layout
+	}
+	S.HasReader = G.Wiegand != nil
+	if G.Sensor != nil {
+		S.HasSensor = true
+		S.GateState, _ = G.State()
+	}
+	return S
+}
+
+// SimSetSensor moves the simulated gate so the position sensor reports open or closed
+func SimSetSensor(gate string, open bool) error {
+	sim, ok := GPIO.(*SimGPIO)
+	if !ok {
+		return fmt.Errorf("Simulator not enabled")
+	}
+	G := CONFIG.GateByName(gate)
+	if G == nil || G.Sensor == nil {
+		return fmt.Errorf("No sensor at gate: %s", gate)
+	}
+	sim.SetInputLevel(G.Sensor.GpioPin, open == G.Sensor.OpenHigh)
+	return nil
+}
+
+// SimPressKey closes the matrix switch for the key, just like a finger on the real keypad
+func SimPressKey(gate string, key string) error {
+	sim, ok := GPIO.(*SimGPIO)
+	if !ok {
+		return fmt.Errorf("Simulator not enabled")
+	}
+	G := CONFIG.GateByName(gate)
+	if G == nil {
+		return fmt.Errorf("Invalid gate: %s", gate)
+	}
+	if G.Keypad != nil && G.Keypad.Type == KeypadType_Evdev {
+		// Play the key through the same decoder as the USB device
+		stream, err := EncodeEvdevKey(key)
+		if err != nil {
+			return err
+		}
+		if G.Keypad.events == nil {
+			return fmt.Errorf("Keypad not running at gate: %s", gate)
+		}
+		if err := readEvdevKeys(bytes.NewReader(stream), G.Keypad.events, G.Keypad.done); err != io.EOF {
+			return err
+		}
+		return nil
+	}
+	row, col, ok := G.Keypad.keyPins(key)
+	if !ok {
+		return fmt.Errorf("Invalid key: %s", key)
+	}
+	sim.Bridge(row, col, true)
+	time.Sleep(100 * time.Millisecond)
+	sim.Bridge(row, col, false)
+	time.Sleep(50 * time.Millisecond) //give the debounce time to see the release
+	return nil
+}
+
+// SimSwipeCard sends the card number from the simulated Wiegand reader, one pulse per bit
+func SimSwipeCard(gate string, card string) error {
+	sim, ok := GPIO.(*SimGPIO)
+	if !ok {
+		return fmt.Errorf("Simulator not enabled")
+	}
+	G := CONFIG.GateByName(gate)
+	if G == nil || G.Wiegand == nil {
+		return fmt.Errorf("No card reader at gate: %s", gate)
+	}
+	bits, err := EncodeWiegand(card)
+	if err != nil {
+		return err
+	}
+	// Lines idle high between the pulses
+	sim.SetInputLevel(G.Wiegand.D0, true)
+	sim.SetInputLevel(G.Wiegand.D1, true)
+	for _, bit := range bits {
+		pin := G.Wiegand.D0
+		if bit {
+			pin = G.Wiegand.D1
+		}
+		sim.SetInputLevel(pin, false)
+		time.Sleep(100 * time.Microsecond)
+		sim.SetInputLevel(pin, true)
+		time.Sleep(2 * time.Millisecond)
+	}
+	return nil
+}
+
+func (P SimPulse) LengthString() string {
+	return P.Length.Round(10 * time.Millisecond).String()
+}
@@ -48,6 +48,7 @@ type Account struct {
 	PwHash        string
 	TempPwHash    string
 	AccountStatus int
+	Language      string //blank = site language
 	TimeCreated   time.Time
 	TimeModified  time.Time
 }
@@ -65,6 +66,14 @@ func (A Account) Status() string {
 	}
 }
 
+// LanguageName is the language picked for the account (for the web pages)
+func (A Account) LanguageName() string {
+	if A.Language == "" {
+		return "Site default (" + languageName(siteLanguage()) + ")"
+	}
+	return languageName(A.Language)
+}
+
 func (A Account) StatusValue() string {
 	return strings.ToLower(A.Status())
 }
@@ -90,6 +99,7 @@ username text not null unique,
 pw_hash text not null,
 temp_pw_hash text not null,
 account_status integer not null,
+language text not null default '',
 time_created integer not null,
 time_modified integer not null
 	);`
@@ -99,10 +109,10 @@ time_modified integer not null
 
 // internal function to read the rows from the account table
 // NOTE: pw_hash is never returned!!
-var accountSelect = `select account_id, first_name, last_name, username, account_status, time_created, time_modified
+var accountSelect = `select account_id, first_name, last_name, username, account_status, language, time_created, time_modified
 	from account`
 
-var fullaccountSelect = `select account_id, first_name, last_name, username, account_status, time_created, time_modified, pw_hash, temp_pw_hash
+var fullaccountSelect = `select account_id, first_name, last_name, username, account_status, language, time_created, time_modified, pw_hash, temp_pw_hash
 	from account`
 
 func (D *Database) parseAccountRows(rows *sql.Rows) ([]Account, error) {
@@ -111,7 +121,7 @@ func (D *Database) parseAccountRows(rows *sql.Rows) ([]Account, error) {
 	var t_created, t_mod int64
 	for rows.Next() {
 		var acc Account
-		if err := rows.Scan(&acc.AccountID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.AccountStatus, &t_created, &t_mod); err != nil {
+		if err := rows.Scan(&acc.AccountID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.AccountStatus, &acc.Language, &t_created, &t_mod); err != nil {
 			return accounts, err
 		}
 		acc.TimeCreated = D.ParseTime(t_created)
@@ -127,7 +137,7 @@ func (D *Database) parseFullAccountRows(rows *sql.Rows) ([]Account, error) {
 	var t_created, t_mod int64
 	for rows.Next() {
 		var acc Account
-		if err := rows.Scan(&acc.AccountID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.AccountStatus, &t_created, &t_mod, &acc.PwHash, &acc.TempPwHash); err != nil {
+		if err := rows.Scan(&acc.AccountID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.AccountStatus, &acc.Language, &t_created, &t_mod, &acc.PwHash, &acc.TempPwHash); err != nil {
 			return accounts, err
 		}
 		acc.TimeCreated = D.ParseTime(t_created)
@@ -142,10 +152,10 @@ func (D *Database) AccountInsert(acc *Account) (*Account, error) {
 		acc.AccountStatus = Account_Active
 	}
 
-	q := `insert into account (first_name, last_name, username, pw_hash, temp_pw_hash, account_status, time_created, time_modified) values
-		(?, ?, ?, ?, ?, ?, ?, ?)
+	q := `insert into account (first_name, last_name, username, pw_hash, temp_pw_hash, account_status, language, time_created, time_modified) values
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)
 		returning account_id;`
-	rslt, err := D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.PwHash, acc.TempPwHash, acc.AccountStatus, D.TimeNow(), D.TimeNow())
+	rslt, err := D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.PwHash, acc.TempPwHash, acc.AccountStatus, acc.Language, D.TimeNow(), D.TimeNow())
 	if err != nil {
 		fmt.Println("Error Inserting Account:", err)
 		return nil, err
@@ -170,9 +180,10 @@ func (D *Database) AccountUpdate(acc *Account) (*Account, error) {
 		pw_hash = ?,
 		temp_pw_hash = '',
 		account_status = ?,
+		language = ?,
 		time_modified = ?
 		where account_id = ?;`
-		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.PwHash, acc.AccountStatus, D.TimeNow(), acc.AccountID)
+		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.PwHash, acc.AccountStatus, acc.Language, D.TimeNow(), acc.AccountID)
 
 	} else if acc.TempPwHash != "" {
 		// Adding a temporary password (do not change current password hash!)
@@ -182,9 +193,10 @@ func (D *Database) AccountUpdate(acc *Account) (*Account, error) {
 		username = ?,
 		temp_pw_hash = ?,
 		account_status = ?,
+		language = ?,
 		time_modified = ?
 		where account_id = ?;`
-		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.TempPwHash, acc.AccountStatus, D.TimeNow(), acc.AccountID)
+		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.TempPwHash, acc.AccountStatus, acc.Language, D.TimeNow(), acc.AccountID)
 
 	} else {
 		// Do not update password hashes (regular updates)
@@ -193,9 +205,10 @@ func (D *Database) AccountUpdate(acc *Account) (*Account, error) {
 		last_name = ?,
 		username = ?,
 		account_status = ?,
+		language = ?,
 		time_modified = ?
 		where account_id = ?;`
-		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.AccountStatus, D.TimeNow(), acc.AccountID)
+		_, err = D.ExecSql(q, acc.FirstName, acc.LastName, strings.ToLower(acc.Username), acc.AccountStatus, acc.Language, D.TimeNow(), acc.AccountID)
 	}
 	if err != nil {
 		fmt.Println("Error Updating Account:", err)
@@ -231,6 +244,16 @@ func (D *Database) AccountFromID(accountId int32) (*Account, error) {
 	return nil, err
 }
 
+// AccountLanguage returns the language picked for the account (or the site language)
+func (D *Database) AccountLanguage(accountId int32) string {
+	if accountId > 0 {
+		if acc, err := D.AccountFromID(accountId); err == nil && acc != nil && acc.Language != "" {
+			return acc.Language
+		}
+	}
+	return siteLanguage()
+}
+
 func (D *Database) AccountFromUser(username string) (*Account, error) {
 	q := accountSelect + " where username = ?;"
 	rows, err := D.QuerySql(q, strings.ToLower(username))
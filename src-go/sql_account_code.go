@@ -17,11 +17,21 @@ func validatePinCodeFormat(p string) bool {
 	return len(p) >= 4
 }
 
+// Types of credentials stored in the account_code table
+const (
+	CodeType_PIN  = "pin"  //typed on the keypad
+	CodeType_Card = "card" //card/fob number from the Wiegand reader
+	// Never stored - used for visitors let in by a resident from the call-box
+	CodeType_Visitor = "visitor"
+)
+
 type AccountCode struct {
 	AccountCodeID int64
 	AccountID     int32
 	Code          string
-	CodeLength    int //Not stored in database - temporary variable
+	CodeType      string //pin or card
+	DuressCode    string //optional PIN which opens the gate but silently alerts the admins
+	CodeLength    int    //Not stored in database - temporary variable
 	Label         string
 	IsActive      bool
 	IsUtility     bool
@@ -33,13 +43,15 @@ type AccountCode struct {
 	TimeStart     time.Time
 	TimeEnd       time.Time
 	ValidDays     []string //2-character abbreviations for days (su, tu, th)
+	Gates         []string //names of the gates the code works at (empty = all gates)
 
 	//Internal audit fields
 	TimeCreated  time.Time
 	TimeModified time.Time
 
-	//Internal pass-through field (not stored in DB)
+	//Internal pass-through fields (not stored in DB)
 	AccountName string
+	Duress      bool //matched by a duress PIN
 }
 
 func (A AccountCode) Status() string {
@@ -49,6 +61,17 @@ func (A AccountCode) Status() string {
 	return "Inactive"
 }
 
+func (A AccountCode) IsCard() bool {
+	return A.CodeType == CodeType_Card
+}
+
+func (A AccountCode) TypeString() string {
+	if A.IsCard() {
+		return "Card"
+	}
+	return "PIN"
+}
+
 func (A AccountCode) TagsString() string {
 	var tags []string
 	if A.IsUtility {
@@ -137,6 +160,25 @@ func (AC *AccountCode) IsValid() bool {
 	return true //All validity checks passed
 }
 
+func (AC AccountCode) AllowsGate(name string) bool {
+	if len(AC.Gates) == 0 {
+		return true
+	}
+	for _, g := range AC.Gates {
+		if g == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (AC AccountCode) GatesString() string {
+	if len(AC.Gates) == 0 {
+		return "All Gates"
+	}
+	return strings.Join(AC.Gates, ", ")
+}
+
 func (AC AccountCode) IsOther() bool {
 	return !(AC.IsUtility || AC.IsDelivery || AC.IsContractor || AC.IsMail)
 }
@@ -146,6 +188,8 @@ func (D *Database) CreateACTable() error {
 account_code_id integer primary key autoincrement,
 account_id not null,
 code text not null unique,
+code_type text not null default 'pin',
+duress_code text not null default '',
 label text not null,
 is_active boolean default false,
 is_utility boolean default false,
@@ -157,6 +201,7 @@ date_end integer,
 time_start integer,
 time_end integer,
 valid_days text,
+gates text not null default '',
 time_created integer not null,
 time_modified integer not null
 	);`
@@ -174,20 +219,33 @@ func splitVDays(days string) []string {
 	return strings.Split(days, ",")
 }
 
+func combineGates(gates []string) string {
+	return strings.Join(gates, ",")
+}
+
+func splitGates(gates string) []string {
+	if gates == "" {
+		return nil //all gates
+	}
+	return strings.Split(gates, ",")
+}
+
 // internal function to read the rows from the account_code table
-var accountCodeSelect = `select account_code_id, account_id, code, label, is_active, is_utility, is_delivery, is_contractor, is_mail, date_start, date_end, time_start, time_end, valid_days, time_created, time_modified
+var accountCodeSelect = `select account_code_id, account_id, code, code_type, duress_code, label, is_active, is_utility, is_delivery, is_contractor, is_mail, date_start, date_end, time_start, time_end, valid_days, gates, time_created, time_modified
 	from account_code`
 
 func (D *Database) parseAccountCodeRows(rows *sql.Rows) ([]AccountCode, error) {
 	defer rows.Close()
 	var accounts []AccountCode
 	var t_created, t_mod, d_s, d_e, t_s, t_e int64
-	var v_days string
+	var v_days, gates string
 	for rows.Next() {
 		var acc AccountCode
 		if err := rows.Scan(&acc.AccountCodeID,
 			&acc.AccountID,
 			&acc.Code,
+			&acc.CodeType,
+			&acc.DuressCode,
 			&acc.Label,
 			&acc.IsActive,
 			&acc.IsUtility,
@@ -199,6 +257,7 @@ func (D *Database) parseAccountCodeRows(rows *sql.Rows) ([]AccountCode, error) {
 			&t_s,
 			&t_e,
 			&v_days,
+			&gates,
 			&t_created,
 			&t_mod); err != nil {
 			return accounts, err
@@ -210,6 +269,7 @@ func (D *Database) parseAccountCodeRows(rows *sql.Rows) ([]AccountCode, error) {
 		acc.TimeStart = D.ParseTime(t_s)
 		acc.TimeEnd = D.ParseTime(t_e)
 		acc.ValidDays = splitVDays(v_days)
+		acc.Gates = splitGates(gates)
 		accounts = append(accounts, acc)
 	}
 	return accounts, nil
@@ -219,6 +279,8 @@ func (D *Database) AccountCodeInsert(acc *AccountCode) (*AccountCode, error) {
 	q := `insert into account_code (
 		account_id,
 		code,
+		code_type,
+		duress_code,
 		label,
 		is_active,
 		is_utility,
@@ -230,13 +292,19 @@ func (D *Database) AccountCodeInsert(acc *AccountCode) (*AccountCode, error) {
 		time_start,
 		time_end,
 		valid_days,
+		gates,
 		time_created,
 		time_modified) values
-		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		returning account_code_id;`
+	if acc.CodeType == "" {
+		acc.CodeType = CodeType_PIN
+	}
 	rslt, err := D.ExecSql(q,
 		acc.AccountID,
 		acc.Code,
+		acc.CodeType,
+		acc.DuressCode,
 		acc.Label,
 		acc.IsActive,
 		acc.IsUtility,
@@ -248,6 +316,7 @@ func (D *Database) AccountCodeInsert(acc *AccountCode) (*AccountCode, error) {
 		D.ToTime(acc.TimeStart),
 		D.ToTime(acc.TimeEnd),
 		combineVDays(acc.ValidDays),
+		combineGates(acc.Gates),
 		D.TimeNow(),
 		D.TimeNow(),
 	)
@@ -267,6 +336,7 @@ func (D *Database) AccountCodeUpdate(acc *AccountCode) (*AccountCode, error) {
 	q := `update account_code set
 		account_id = ?,
 		code = ?,
+		duress_code = ?,
 		label = ?,
 		is_active = ?,
 		is_utility = ?,
@@ -278,11 +348,13 @@ func (D *Database) AccountCodeUpdate(acc *AccountCode) (*AccountCode, error) {
 		time_start = ?,
 		time_end = ?,
 		valid_days = ?,
+		gates = ?,
 		time_modified = ?
 		where account_code_id = ?;`
 	_, err := D.ExecSql(q,
 		acc.AccountID,
 		acc.Code,
+		acc.DuressCode,
 		acc.Label,
 		acc.IsActive,
 		acc.IsUtility,
@@ -294,6 +366,7 @@ func (D *Database) AccountCodeUpdate(acc *AccountCode) (*AccountCode, error) {
 		D.ToTime(acc.TimeStart),
 		D.ToTime(acc.TimeEnd),
 		combineVDays(acc.ValidDays),
+		combineGates(acc.Gates),
 		D.TimeNow(),
 		acc.AccountCodeID,
 	)
@@ -346,6 +419,87 @@ func (D *Database) AccountCodeMatch(code string) (*AccountCode, error) {
 	return &accounts[0], nil
 }
 
+// AccountCodeDuressMatch finds the PIN code a duress PIN belongs to (nil if it is not a duress PIN)
+// Real PINs always win, so check AccountCodeMatch first
+func (D *Database) AccountCodeDuressMatch(pin string) (*AccountCode, error) {
+	q := accountCodeSelect + " where code_type = ? and duress_code = ?;"
+	rows, err := D.QuerySql(q, CodeType_PIN, pin)
+	if err != nil {
+		fmt.Println("Error Selecting AccountCode from duress code:", err)
+		return nil, err
+	}
+	accounts, err := D.parseAccountCodeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) != 1 && CONFIG.DuressLastDigit {
+		// PIN with the last digit incremented
+		if owner, ok := shiftLastDigit(pin, -1); ok {
+			rows, err = D.QuerySql(accountCodeSelect+" where code_type = ? and code = ?;", CodeType_PIN, owner)
+			if err != nil {
+				fmt.Println("Error Selecting AccountCode from duress PIN:", err)
+				return nil, err
+			}
+			accounts, err = D.parseAccountCodeRows(rows)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(accounts) != 1 {
+		return nil, nil
+	}
+	accounts[0].Duress = true
+	return &accounts[0], nil
+}
+
+// shiftLastDigit adds to the last digit of the PIN (wrapping around, so 9+1 = 0)
+func shiftLastDigit(pin string, by int) (string, bool) {
+	if len(pin) == 0 || pin[len(pin)-1] < '0' || pin[len(pin)-1] > '9' {
+		return "", false
+	}
+	last := (int(pin[len(pin)-1]-'0') + by + 10) % 10
+	return pin[:len(pin)-1] + string(rune('0'+last)), true
+}
+
+// AccountCodeIsLastDigitDuress reports whether the PIN is the last-digit duress PIN for an existing PIN
+// (only when "duress_pin_last_digit" is turned on)
+func (D *Database) AccountCodeIsLastDigitDuress(pin string) bool {
+	owner, ok := shiftLastDigit(pin, -1)
+	if !CONFIG.DuressLastDigit || !ok {
+		return false
+	}
+	rows, err := D.QuerySql("select account_code_id from account_code where code_type = ? and code = ?;", CodeType_PIN, owner)
+	if err != nil {
+		fmt.Println("Error Checking AccountCode:", err)
+		return true
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
+// AccountCodePINTaken reports whether a new PIN would get mixed up with an existing PIN or duress PIN.
+// The real PIN always wins at the keypad, so a PIN matching somebody else's duress PIN would stop their alarm.
+func (D *Database) AccountCodePINTaken(pin string) bool {
+	if D.AccountCodeInUse(pin) || D.AccountCodeIsLastDigitDuress(pin) {
+		return true
+	}
+	// The new PIN's own last-digit duress PIN cannot belong to anybody else either
+	duress, ok := shiftLastDigit(pin, 1)
+	return CONFIG.DuressLastDigit && ok && D.AccountCodeInUse(duress)
+}
+
+// AccountCodeInUse reports whether the code is already used as a PIN, card, or duress PIN
+func (D *Database) AccountCodeInUse(code string) bool {
+	rows, err := D.QuerySql("select account_code_id from account_code where code = ? or duress_code = ?;", code, code)
+	if err != nil {
+		fmt.Println("Error Checking AccountCode:", err)
+		return true
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
 func (D *Database) PruneAccountCodes(before time.Time) error {
 	q := `DELETE from account_code where is_active = false and time_modified < ?;`
 	_, err := D.ExecSql(q, D.ToTime(before))
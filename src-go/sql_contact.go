@@ -19,6 +19,7 @@ type Contact struct {
 	IsDelivery   bool
 	IsContractor bool
 	IsMail       bool
+	Language     string //from the account (blank = site language)
 	//Internal audit fields
 	TimeCreated  time.Time
 	TimeModified time.Time
@@ -32,6 +33,14 @@ func (C Contact) ContactEmail() string {
 	return eml
 }
 
+// MessageLanguage is the language for notifications sent to this contact
+func (C Contact) MessageLanguage() string {
+	if C.Language != "" {
+		return C.Language
+	}
+	return siteLanguage()
+}
+
 func (C Contact) Display() string {
 	if C.Email != "" {
 		return C.Email
@@ -103,7 +112,8 @@ time_modified integer not null
 	return err
 }
 
-const contactquery = `select contact_id, account_id, email, phone_num, cell_type, is_primary, is_active, is_utility, is_delivery, is_contractor, is_mail, time_created, time_modified from contact`
+const contactquery = `select contact_id, account_id, email, phone_num, cell_type, is_primary, is_active, is_utility, is_delivery, is_contractor, is_mail, time_created, time_modified,
+	coalesce((select a.language from account a where a.account_id = contact.account_id), '') from contact`
 
 // internal function to read the rows from the table
 func (D *Database) parseContactRows(rows *sql.Rows, with_picture bool) ([]Contact, error) {
@@ -113,7 +123,7 @@ func (D *Database) parseContactRows(rows *sql.Rows, with_picture bool) ([]Contac
 	for rows.Next() {
 		var c Contact
 		var err error
-		if err = rows.Scan(&c.ContactID, &c.AccountID, &c.Email, &c.PhoneNum, &c.CellType, &c.IsPrimary, &c.IsActive, &c.IsUtility, &c.IsDelivery, &c.IsContractor, &c.IsMail, &t_create, &t_mod); err != nil {
+		if err = rows.Scan(&c.ContactID, &c.AccountID, &c.Email, &c.PhoneNum, &c.CellType, &c.IsPrimary, &c.IsActive, &c.IsUtility, &c.IsDelivery, &c.IsContractor, &c.IsMail, &t_create, &t_mod, &c.Language); err != nil {
 			return list, err
 		}
 		c.TimeCreated = D.ParseTime(t_create)
@@ -227,6 +237,16 @@ func (D *Database) ContactsForAccountNotify(accid int32) ([]Contact, error) {
 	return D.parseContactRows(rows, true)
 }
 
+// ContactsForAdminNotify returns the active contacts of all the admin accounts
+func (D *Database) ContactsForAdminNotify() ([]Contact, error) {
+	q := contactquery + ` where is_active = true and (account_id = -1 or account_id in (select account_id from account where account_status = ?));`
+	rows, err := D.QuerySql(q, Account_Admin)
+	if err != nil {
+		return nil, err
+	}
+	return D.parseContactRows(rows, true)
+}
+
 func (D *Database) ContactFromID(contactId int64) (*Contact, error) {
 	q := contactquery + ` where contact_id = ?;`
 	rows, err := D.QuerySql(q, contactId)
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DirectoryEntry is a unit/directory number a visitor can call from the gate keypad
+type DirectoryEntry struct {
+	EntryID   int64
+	Number    string //typed on the keypad
+	AccountID int32  //resident who gets the call
+	Label     string //shown on the LCD (keep it short)
+	IsActive  bool
+
+	//Internal audit fields
+	TimeCreated  time.Time
+	TimeModified time.Time
+
+	//Internal pass-through field (not stored in DB)
+	AccountName string
+}
+
+func (E DirectoryEntry) Status() string {
+	if E.IsActive {
+		return "Active"
+	}
+	return "Inactive"
+}
+
+func (D *Database) CreateDirectoryTable() error {
+	q := `create table if not exists directory (
+entry_id integer primary key autoincrement,
+number text not null unique,
+account_id integer not null,
+label text not null,
+is_active boolean default false,
+time_created integer not null,
+time_modified integer not null
+	);`
+	_, err := D.ExecSql(q)
+	return err
+}
+
+var directorySelect = `select entry_id, number, account_id, label, is_active, time_created, time_modified
+	from directory`
+
+func (D *Database) parseDirectoryRows(rows *sql.Rows) ([]DirectoryEntry, error) {
+	defer rows.Close()
+	var list []DirectoryEntry
+	var t_created, t_mod int64
+	for rows.Next() {
+		var E DirectoryEntry
+		if err := rows.Scan(&E.EntryID, &E.Number, &E.AccountID, &E.Label, &E.IsActive, &t_created, &t_mod); err != nil {
+			return list, err
+		}
+		E.TimeCreated = D.ParseTime(t_created)
+		E.TimeModified = D.ParseTime(t_mod)
+		list = append(list, E)
+	}
+	return list, nil
+}
+
+func (D *Database) DirectoryInsert(E *DirectoryEntry) (*DirectoryEntry, error) {
+	q := `insert into directory (number, account_id, label, is_active, time_created, time_modified) values
+		(?, ?, ?, ?, ?, ?)
+		returning entry_id;`
+	rslt, err := D.ExecSql(q, E.Number, E.AccountID, E.Label, E.IsActive, D.TimeNow(), D.TimeNow())
+	if err != nil {
+		fmt.Println("Error Inserting DirectoryEntry:", err)
+		return nil, err
+	}
+	E.EntryID, err = rslt.LastInsertId()
+	return E, err
+}
+
+func (D *Database) DirectoryUpdate(E *DirectoryEntry) (*DirectoryEntry, error) {
+	if E.EntryID < 1 {
+		return nil, fmt.Errorf("Missing Entry ID for DirectoryUpdate")
+	}
+	E.TimeModified = time.Now()
+	q := `update directory set
+		number = ?,
+		account_id = ?,
+		label = ?,
+		is_active = ?,
+		time_modified = ?
+		where entry_id = ?;`
+	_, err := D.ExecSql(q, E.Number, E.AccountID, E.Label, E.IsActive, D.TimeNow(), E.EntryID)
+	if err != nil {
+		fmt.Println("Error Updating DirectoryEntry:", err)
+		return nil, err
+	}
+	return E, nil
+}
+
+func (D *Database) DirectorySelectAll(entryId int64) ([]DirectoryEntry, error) {
+	//entryId = 0 means return everything
+	q := directorySelect
+	var conditions []string
+	var args []interface{}
+	if entryId > 0 {
+		conditions = append(conditions, "entry_id = ?")
+		args = append(args, entryId)
+	}
+	if len(conditions) > 0 {
+		q += " where " + strings.Join(conditions, " and ")
+	}
+	rows, err := D.QuerySql(q+" order by number;", args...)
+	if err != nil {
+		fmt.Println("Error Selecting Directory:", err)
+		return nil, err
+	}
+	return D.parseDirectoryRows(rows)
+}
+
+// DirectoryMatch returns the entry for the number (nil if there is no such entry)
+// activeOnly skips the entries which have been turned off
+func (D *Database) DirectoryMatch(number string, activeOnly bool) (*DirectoryEntry, error) {
+	q := directorySelect + " where number = ?"
+	if activeOnly {
+		q += " and is_active = true"
+	}
+	rows, err := D.QuerySql(q+";", number)
+	if err != nil {
+		fmt.Println("Error Selecting DirectoryEntry from number:", err)
+		return nil, err
+	}
+	list, err := D.parseDirectoryRows(rows)
+	if err != nil || len(list) != 1 {
+		return nil, err
+	}
+	return &list[0], nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GateHold keeps the gate open instead of the usual short pulse.
+// Schedules repeat using the same date/time/day rules as AccountCode,
+// manual holds just stay open until the HoldUntil time.
+type GateHold struct {
+	HoldID    int64
+	AccountID int32 //who created it
+	Label     string
+	GateName  string //blank = all gates
+	IsActive  bool
+	IsManual  bool
+	HoldUntil time.Time //manual holds only
+	DateStart time.Time
+	DateEnd   time.Time
+	TimeStart time.Time
+	TimeEnd   time.Time
+	ValidDays []string //2-character abbreviations for days (su, tu, th)
+
+	//Internal audit fields
+	TimeCreated  time.Time
+	TimeModified time.Time
+}
+
+func (H GateHold) Status() string {
+	if H.IsActive {
+		return "Active"
+	}
+	return "Inactive"
+}
+
+// AppliesTo reports whether the hold is for the named gate
+func (H GateHold) AppliesTo(gate string) bool {
+	return H.GateName == "" || H.GateName == gate
+}
+
+func (H GateHold) GateString() string {
+	if H.GateName == "" {
+		return "All Gates"
+	}
+	return H.GateName
+}
+
+func (H GateHold) HasDay(d string) bool {
+	return AccountCode{ValidDays: H.ValidDays}.HasDay(d)
+}
+
+func (H GateHold) WhenString() string {
+	if H.IsManual {
+		return "Until " + H.HoldUntil.Format("Jan _2, 2006 3:04PM")
+	}
+	return AccountCode{
+		DateStart: H.DateStart,
+		DateEnd:   H.DateEnd,
+		TimeStart: H.TimeStart,
+		TimeEnd:   H.TimeEnd,
+		ValidDays: H.ValidDays,
+	}.WhenValidString()
+}
+
+// OpenNow reports whether the gate should be held open right now
+func (H *GateHold) OpenNow() bool {
+	if !H.IsActive {
+		return false
+	}
+	now := time.Now()
+	if H.IsManual {
+		return now.Before(H.HoldUntil)
+	}
+	if !H.DateStart.IsZero() && now.Before(H.DateStart) {
+		return false
+	}
+	if !H.DateEnd.IsZero() && now.After(H.DateEnd) {
+		return false
+	}
+	if !nowValidWeekday(H.ValidDays) {
+		return false
+	}
+	if H.TimeStart.IsZero() || H.TimeEnd.IsZero() {
+		return false //schedules always need a window of time
+	}
+	return nowBetweenTimes(H.TimeStart, H.TimeEnd)
+}
+
+func (D *Database) CreateGateHoldTable() error {
+	q := `create table if not exists gate_hold (
+hold_id integer primary key autoincrement,
+account_id integer not null,
+label text not null,
+gate_name text not null default '',
+is_active boolean default false,
+is_manual boolean default false,
+hold_until integer,
+date_start integer,
+date_end integer,
+time_start integer,
+time_end integer,
+valid_days text,
+time_created integer not null,
+time_modified integer not null
+	);`
+	_, err := D.ExecSql(q)
+	return err
+}
+
+var gateHoldSelect = `select hold_id, account_id, label, gate_name, is_active, is_manual, hold_until, date_start, date_end, time_start, time_end, valid_days, time_created, time_modified
+	from gate_hold`
+
+func (D *Database) parseGateHoldRows(rows *sql.Rows) ([]GateHold, error) {
+	defer rows.Close()
+	var list []GateHold
+	var t_created, t_mod, h_u, d_s, d_e, t_s, t_e int64
+	var v_days string
+	for rows.Next() {
+		var H GateHold
+		if err := rows.Scan(&H.HoldID, &H.AccountID, &H.Label, &H.GateName, &H.IsActive, &H.IsManual, &h_u, &d_s, &d_e, &t_s, &t_e, &v_days, &t_created, &t_mod); err != nil {
+			return list, err
+		}
+		H.HoldUntil = D.ParseTime(h_u)
+		H.DateStart = D.ParseTime(d_s)
+		H.DateEnd = D.ParseTime(d_e)
+		H.TimeStart = D.ParseTime(t_s)
+		H.TimeEnd = D.ParseTime(t_e)
+		H.ValidDays = splitVDays(v_days)
+		H.TimeCreated = D.ParseTime(t_created)
+		H.TimeModified = D.ParseTime(t_mod)
+		list = append(list, H)
+	}
+	return list, nil
+}
+
+func (D *Database) GateHoldInsert(H *GateHold) (*GateHold, error) {
+	q := `insert into gate_hold (account_id, label, gate_name, is_active, is_manual, hold_until, date_start, date_end, time_start, time_end, valid_days, time_created, time_modified) values
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		returning hold_id;`
+	rslt, err := D.ExecSql(q,
+		H.AccountID,
+		H.Label,
+		H.GateName,
+		H.IsActive,
+		H.IsManual,
+		D.ToTime(H.HoldUntil),
+		D.ToTime(H.DateStart),
+		D.ToTime(H.DateEnd),
+		D.ToTime(H.TimeStart),
+		D.ToTime(H.TimeEnd),
+		combineVDays(H.ValidDays),
+		D.TimeNow(),
+		D.TimeNow(),
+	)
+	if err != nil {
+		fmt.Println("Error Inserting GateHold:", err)
+		return nil, err
+	}
+	H.HoldID, err = rslt.LastInsertId()
+	return H, err
+}
+
+func (D *Database) GateHoldUpdate(H *GateHold) (*GateHold, error) {
+	if H.HoldID < 1 {
+		return nil, fmt.Errorf("Missing Hold ID for GateHoldUpdate")
+	}
+	H.TimeModified = time.Now()
+	q := `update gate_hold set
+		label = ?,
+		gate_name = ?,
+		is_active = ?,
+		hold_until = ?,
+		date_start = ?,
+		date_end = ?,
+		time_start = ?,
+		time_end = ?,
+		valid_days = ?,
+		time_modified = ?
+		where hold_id = ?;`
+	_, err := D.ExecSql(q,
+		H.Label,
+		H.GateName,
+		H.IsActive,
+		D.ToTime(H.HoldUntil),
+		D.ToTime(H.DateStart),
+		D.ToTime(H.DateEnd),
+		D.ToTime(H.TimeStart),
+		D.ToTime(H.TimeEnd),
+		combineVDays(H.ValidDays),
+		D.TimeNow(),
+		H.HoldID,
+	)
+	if err != nil {
+		fmt.Println("Error Updating GateHold:", err)
+		return nil, err
+	}
+	return H, nil
+}
+
+func (D *Database) GateHoldSelectAll(holdId int64, activeOnly bool) ([]GateHold, error) {
+	//holdId = 0 means return everything
+	q := gateHoldSelect
+	var conditions []string
+	var args []interface{}
+	if holdId > 0 {
+		conditions = append(conditions, "hold_id = ?")
+		args = append(args, holdId)
+	}
+	if activeOnly {
+		conditions = append(conditions, "is_active = true")
+	}
+	if len(conditions) > 0 {
+		q += " where " + strings.Join(conditions, " and ")
+	}
+	rows, err := D.QuerySql(q+" order by is_manual desc, time_created desc;", args...)
+	if err != nil {
+		fmt.Println("Error Selecting GateHolds:", err)
+		return nil, err
+	}
+	return D.parseGateHoldRows(rows)
+}
+
+// GateHoldEndManual deactivates the manual holds on the gate (schedules are left alone)
+// Manual holds for all gates get ended too, since they are keeping this gate open
+func (D *Database) GateHoldEndManual(gate string) error {
+	q := `update gate_hold set is_active = false, time_modified = ? where is_manual = true and is_active = true and (gate_name = ? or gate_name = '');`
+	_, err := D.ExecSql(q, D.TimeNow(), gate)
+	return err
+}
+
+func (D *Database) PruneGateHolds(before time.Time) error {
+	q := `DELETE from gate_hold where (is_active = false or (is_manual = true and hold_until < ?)) and time_modified < ?;`
+	_, err := D.ExecSql(q, D.ToTime(before), D.ToTime(before))
+	return err
+}
@@ -2,10 +2,25 @@ package main
 
 import (
 	"database/sql"
-	"encoding/base64"
+	"fmt"
 	"time"
 )
 
+// Types of gate log entries (blank = regular open request)
+const (
+	GateEvent_Open       = ""
+	GateEvent_HoldStart  = "hold_start"
+	GateEvent_HoldEnd    = "hold_end"
+	GateEvent_LeftOpen   = "left_open"
+	GateEvent_OpenFailed = "open_failed"
+	GateEvent_Forced     = "forced"
+	GateEvent_Lockout    = "lockout"
+	GateEvent_Card       = "card"     //card/fob from the Wiegand reader
+	GateEvent_Visitor    = "visitor"  //visitor let in by a resident from the call-box
+	GateEvent_Duress     = "duress"   //opened with a duress PIN (admins alerted)
+	GateEvent_Presence   = "presence" //motion seen by the gate camera
+)
+
 type GateLog struct {
 	LogID       int64
 	AccountID   int32
@@ -13,18 +28,48 @@ type GateLog struct {
 	UsedCode    string
 	UsedWeb     bool
 	CodeTags    string
-	GatePicture []byte
+	EventType   string
+	GateName    string
+	GatePicture []byte //picture for a new entry (moved into the picture store when inserted)
+	PictureHash string //picture in the picture store
+	ClipFile    string //video from around the event (in the clips directory)
 	TimeOpened  time.Time
 	Success     bool
 }
 
 func (G GateLog) OpenedBy() string {
+	switch G.EventType {
+	case GateEvent_HoldStart:
+		return "Hold Open Started"
+	case GateEvent_HoldEnd:
+		return "Hold Open Ended"
+	case GateEvent_LeftOpen:
+		return "Left Open"
+	case GateEvent_OpenFailed:
+		return "Failed to Open"
+	case GateEvent_Forced:
+		return "Forced Entry"
+	case GateEvent_Lockout:
+		return "Keypad Locked"
+	case GateEvent_Card:
+		return "Card"
+	case GateEvent_Visitor:
+		return "Visitor"
+	case GateEvent_Duress:
+		return "Duress PIN"
+	case GateEvent_Presence:
+		return "Presence"
+	}
 	if G.UsedWeb {
 		return "Web"
 	}
 	return "PIN"
 }
 
+func (G GateLog) IsCard() bool {
+	return G.EventType == GateEvent_Card
+}
+
 func (G GateLog) ShowPIN(accid int32) string {
 	if accid == G.AccountID {
 		return G.UsedCode
@@ -33,14 +78,11 @@ func (G GateLog) ShowPIN(accid int32) string {
 }
 
 func (G GateLog) HasImage() bool {
-	return len(G.GatePicture) > 0
+	return G.PictureHash != ""
 }
 
-func (G GateLog) ImageBase64() string {
-	if !G.HasImage() {
-		return ""
-	}
-	return base64.StdEncoding.EncodeToString(G.GatePicture)
+func (G GateLog) HasClip() bool {
+	return G.ClipFile != ""
 }
 
 func (D *Database) CreateGateLogTable() error {
@@ -51,7 +93,10 @@ opened_name text,
 used_code text,
 used_web boolean,
 code_tags text,
-gate_picture_bytes blob,
+event_type text not null default '',
+gate_name text not null default '',
+picture_hash text not null default '',
+clip_file text not null default '',
 time_opened integer not null,
 success boolean
 	);`
@@ -60,21 +105,14 @@ success boolean
 }
 
 // internal function to read the rows from the table
-func (D *Database) parseGatelogRows(rows *sql.Rows, with_picture bool) ([]GateLog, error) {
+func (D *Database) parseGatelogRows(rows *sql.Rows) ([]GateLog, error) {
 	defer rows.Close()
 	var list []GateLog
 	var t_opened int64
 	for rows.Next() {
 		var gl GateLog
-		var err error
-		if with_picture {
-			if err = rows.Scan(&gl.LogID, &gl.AccountID, &gl.OpenedName, &gl.UsedCode, &gl.UsedWeb, &gl.CodeTags, &gl.GatePicture, &t_opened, &gl.Success); err != nil {
-				return list, err
-			}
-		} else {
-			if err = rows.Scan(&gl.LogID, &gl.AccountID, &gl.OpenedName, &gl.UsedCode, &gl.UsedWeb, &gl.CodeTags, &t_opened, &gl.Success); err != nil {
-				return list, err
-			}
+		if err := rows.Scan(&gl.LogID, &gl.AccountID, &gl.OpenedName, &gl.UsedCode, &gl.UsedWeb, &gl.CodeTags, &gl.EventType, &gl.GateName, &gl.ClipFile, &gl.PictureHash, &t_opened, &gl.Success); err != nil {
+			return list, err
 		}
 		gl.TimeOpened = D.ParseTime(t_opened)
 		list = append(list, gl)
@@ -83,10 +121,17 @@ func (D *Database) parseGatelogRows(rows *sql.Rows, with_picture bool) ([]GateLo
 }
 
 func (D *Database) GateLogInsert(gl *GateLog) (*GateLog, error) {
-	q := `insert into gatelog (account_id, opened_name, used_code, used_web, code_tags, gate_picture_bytes, time_opened, success) values
-		(?, ?, ?, ?, ?, ?, ?, ?)
+	if len(gl.GatePicture) > 0 && gl.PictureHash == "" {
+		hash, err := D.SavePicture(gl.GatePicture)
+		if err != nil {
+			fmt.Println("Error saving gate picture:", err) //still log the event
+		}
+		gl.PictureHash = hash
+	}
+	q := `insert into gatelog (account_id, opened_name, used_code, used_web, code_tags, event_type, gate_name, picture_hash, time_opened, success) values
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		returning log_id;`
-	rslt, err := D.ExecSql(q, gl.AccountID, gl.OpenedName, gl.UsedCode, gl.UsedWeb, gl.CodeTags, gl.GatePicture, D.TimeNow(), gl.Success)
+	rslt, err := D.ExecSql(q, gl.AccountID, gl.OpenedName, gl.UsedCode, gl.UsedWeb, gl.CodeTags, gl.EventType, gl.GateName, gl.PictureHash, D.TimeNow(), gl.Success)
 	if err != nil {
 		return nil, err
 	}
@@ -96,41 +141,70 @@ func (D *Database) GateLogInsert(gl *GateLog) (*GateLog, error) {
 }
 
 func (D *Database) GatelogSelectAll() ([]GateLog, error) {
-	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, time_opened, success
+	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, event_type, gate_name, clip_file, picture_hash, time_opened, success
 	from gatelog order by time_opened desc limit 1000;`
 	rows, err := D.QuerySql(q)
 	if err != nil {
 		return nil, err
 	}
-	return D.parseGatelogRows(rows, false)
+	return D.parseGatelogRows(rows)
 }
 
 func (D *Database) GatelogSelectAccount(account int32) ([]GateLog, error) {
-	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, time_opened, success
+	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, event_type, gate_name, clip_file, picture_hash, time_opened, success
 	from gatelog where account_id = ? order by time_opened desc limit 1000;`
 	rows, err := D.QuerySql(q, account)
 	if err != nil {
 		return nil, err
 	}
-	return D.parseGatelogRows(rows, false)
+	return D.parseGatelogRows(rows)
 }
 
 func (D *Database) GateLogFromID(logId int64) (*GateLog, error) {
-	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, gate_picture_bytes, time_opened, success
+	q := `select log_id, account_id, opened_name, used_code, used_web, code_tags, event_type, gate_name, clip_file, picture_hash, time_opened, success
 	from gatelog where log_id = ?;`
 	rows, err := D.QuerySql(q, logId)
 	if err != nil {
 		return nil, err
 	}
-	list, err := D.parseGatelogRows(rows, true)
+	list, err := D.parseGatelogRows(rows)
 	if len(list) >= 1 {
 		return &list[0], err
 	}
 	return nil, err
 }
 
+// GateLogSetClip records the video clip saved for the log entry
+func (D *Database) GateLogSetClip(logId int64, clipFile string) error {
+	q := `update gatelog set clip_file = ? where log_id = ?;`
+	_, err := D.ExecSql(q, clipFile, logId)
+	return err
+}
+
 func (D *Database) PruneGateLogs(before time.Time) error {
+	// Clip files and pictures go away with their log entries
+	rows, err := D.QuerySql(`select clip_file, picture_hash from gatelog where time_opened < ? and (clip_file != '' or picture_hash != '');`, D.ToTime(before))
+	if err != nil {
+		return err
+	}
+	var clips, pictures []string
+	for rows.Next() {
+		var name, hash string
+		if err := rows.Scan(&name, &hash); err == nil {
+			if name != "" {
+				clips = append(clips, name)
+			}
+			if hash != "" {
+				pictures = append(pictures, hash)
+			}
+		}
+	}
+	rows.Close()
 	q := `DELETE from gatelog where time_opened < ?;`
-	_, err := D.ExecSql(q, D.ToTime(before))
+	_, err = D.ExecSql(q, D.ToTime(before))
+	if err == nil {
+		removeClips(clips)
+		D.removePictures(pictures) //only the ones which are not used by newer entries
+	}
 	return err
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// KeypadLockout is the brute-force lockout state for the keypad at a gate
+type KeypadLockout struct {
+	GateName     string
+	LockedUntil  time.Time
+	LockLevel    int //number of lockouts in a row (each one is longer)
+	TimeModified time.Time
+
+	//Internal pass-through field (not stored in DB)
+	Failures int //bad PINs within the current window
+}
+
+func (L KeypadLockout) IsLocked() bool {
+	return time.Now().Before(L.LockedUntil)
+}
+
+func (L KeypadLockout) LockedString() string {
+	if !L.IsLocked() {
+		return "Unlocked"
+	}
+	return "Locked until " + L.LockedUntil.Format("Jan _2 3:04:05PM")
+}
+
+func (D *Database) CreateKeypadLockoutTables() error {
+	q := `create table if not exists keypad_failure (
+failure_id integer primary key autoincrement,
+gate_name text not null,
+time_failed integer not null
+	);`
+	_, err := D.ExecSql(q)
+	if err != nil {
+		return err
+	}
+	q = `create table if not exists keypad_lockout (
+gate_name text primary key,
+locked_until integer,
+lock_level integer not null default 0,
+time_modified integer not null
+	);`
+	_, err = D.ExecSql(q)
+	return err
+}
+
+func (D *Database) KeypadFailureInsert(gate string) error {
+	q := `insert into keypad_failure (gate_name, time_failed) values (?, ?);`
+	_, err := D.ExecSql(q, gate, D.TimeNow())
+	if err != nil {
+		fmt.Println("Error Inserting KeypadFailure:", err)
+	}
+	return err
+}
+
+// KeypadFailureCount returns the number of bad PINs at the gate since the given time
+func (D *Database) KeypadFailureCount(gate string, since time.Time) (int, error) {
+	q := `select count(*) from keypad_failure where gate_name = ? and time_failed > ?;`
+	rows, err := D.QuerySql(q, gate, D.ToTime(since))
+	if err != nil {
+		fmt.Println("Error Counting KeypadFailures:", err)
+		return 0, err
+	}
+	defer rows.Close()
+	count := 0
+	if rows.Next() {
+		err = rows.Scan(&count)
+	}
+	return count, err
+}
+
+func (D *Database) parseKeypadLockoutRows(rows *sql.Rows) ([]KeypadLockout, error) {
+	defer rows.Close()
+	var list []KeypadLockout
+	var l_until, t_mod int64
+	for rows.Next() {
+		var L KeypadLockout
+		if err := rows.Scan(&L.GateName, &l_until, &L.LockLevel, &t_mod); err != nil {
+			return list, err
+		}
+		L.LockedUntil = D.ParseTime(l_until)
+		L.TimeModified = D.ParseTime(t_mod)
+		list = append(list, L)
+	}
+	return list, nil
+}
+
+// KeypadLockoutSelect returns the lockout state for the gate (never locked = empty state)
+func (D *Database) KeypadLockoutSelect(gate string) (KeypadLockout, error) {
+	q := `select gate_name, locked_until, lock_level, time_modified from keypad_lockout where gate_name = ?;`
+	rows, err := D.QuerySql(q, gate)
+	if err != nil {
+		fmt.Println("Error Selecting KeypadLockout:", err)
+		return KeypadLockout{GateName: gate}, err
+	}
+	list, err := D.parseKeypadLockoutRows(rows)
+	if len(list) < 1 {
+		return KeypadLockout{GateName: gate}, err
+	}
+	return list[0], err
+}
+
+func (D *Database) KeypadLockoutSave(L *KeypadLockout) error {
+	L.TimeModified = time.Now()
+	q := `insert or replace into keypad_lockout (gate_name, locked_until, lock_level, time_modified) values
+		(?, ?, ?, ?);`
+	_, err := D.ExecSql(q, L.GateName, D.ToTime(L.LockedUntil), L.LockLevel, D.TimeNow())
+	if err != nil {
+		fmt.Println("Error Saving KeypadLockout:", err)
+	}
+	return err
+}
+
+// KeypadLockoutClear unlocks the keypad and forgets all the bad PINs for the gate
+func (D *Database) KeypadLockoutClear(gate string) error {
+	_, err := D.ExecSql(`DELETE from keypad_failure where gate_name = ?;`, gate)
+	if err != nil {
+		return err
+	}
+	_, err = D.ExecSql(`DELETE from keypad_lockout where gate_name = ?;`, gate)
+	return err
+}
+
+func (D *Database) PruneKeypadFailures(before time.Time) error {
+	q := `DELETE from keypad_failure where time_failed < ?;`
+	_, err := D.ExecSql(q, D.ToTime(before))
+	return err
+}
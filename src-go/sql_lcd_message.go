@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LCDMessage is shown on the gate LCD whenever nothing else is on it (the idle screen).
+// It uses the same date/time/day rules as AccountCode, and with no times set it shows all day.
+// The text can include {site}, {time} and {date}, which are filled in when it is shown.
+type LCDMessage struct {
+	MessageID   int64
+	AccountID   int32 //who created it
+	Label       string
+	Text        string //"\n" starts a new line on LCDs with more than one line
+	GateName    string //blank = all gates
+	IsActive    bool
+	BacklightOn bool //keep the backlight on while the message is shown
+	DateStart   time.Time
+	DateEnd     time.Time
+	TimeStart   time.Time
+	TimeEnd     time.Time
+	ValidDays   []string //2-character abbreviations for days (su, tu, th)
+
+	//Internal audit fields
+	TimeCreated  time.Time
+	TimeModified time.Time
+}
+
+func (M LCDMessage) Status() string {
+	if M.IsActive {
+		return "Active"
+	}
+	return "Inactive"
+}
+
+// AppliesTo reports whether the message is for the named gate
+func (M LCDMessage) AppliesTo(gate string) bool {
+	return M.GateName == "" || M.GateName == gate
+}
+
+func (M LCDMessage) GateString() string {
+	if M.GateName == "" {
+		return "All Gates"
+	}
+	return M.GateName
+}
+
+func (M LCDMessage) HasDay(d string) bool {
+	return AccountCode{ValidDays: M.ValidDays}.HasDay(d)
+}
+
+func (M LCDMessage) WhenString() string {
+	if M.DateStart.IsZero() && M.DateEnd.IsZero() && M.TimeStart.IsZero() && (len(M.ValidDays) == 0 || len(M.ValidDays) == 7) {
+		return "Always"
+	}
+	return AccountCode{
+		DateStart: M.DateStart,
+		DateEnd:   M.DateEnd,
+		TimeStart: M.TimeStart,
+		TimeEnd:   M.TimeEnd,
+		ValidDays: M.ValidDays,
+	}.WhenValidString()
+}
+
+// ShowNow reports whether the message is scheduled for right now
+func (M LCDMessage) ShowNow() bool {
+	if !M.IsActive {
+		return false
+	}
+	now := time.Now()
+	if !M.DateStart.IsZero() && now.Before(M.DateStart) {
+		return false
+	}
+	if !M.DateEnd.IsZero() && now.After(M.DateEnd) {
+		return false
+	}
+	if !nowValidWeekday(M.ValidDays) {
+		return false
+	}
+	return nowBetweenTimes(M.TimeStart, M.TimeEnd)
+}
+
+// Render fills in the placeholders in the text
+func (M LCDMessage) Render(now time.Time) string {
+	return strings.NewReplacer(
+		"{site}", CONFIG.SiteName,
+		"{time}", now.Format("3:04PM"),
+		"{date}", now.Format("Jan _2"),
+		"\r\n", "\n", //line breaks from the web form
+	).Replace(M.Text)
+}
+
+func (D *Database) CreateLCDMessageTable() error {
+	q := `create table if not exists lcd_message (
+message_id integer primary key autoincrement,
+account_id integer not null,
+label text not null,
+message text not null,
+gate_name text not null default '',
+is_active boolean default false,
+backlight_on boolean default false,
+date_start integer,
+date_end integer,
+time_start integer,
+time_end integer,
+valid_days text,
+time_created integer not null,
+time_modified integer not null
+	);`
+	_, err := D.ExecSql(q)
+	return err
+}
+
+var lcdMessageSelect = `select message_id, account_id, label, message, gate_name, is_active, backlight_on, date_start, date_end, time_start, time_end, valid_days, time_created, time_modified
+	from lcd_message`
+
+func (D *Database) parseLCDMessageRows(rows *sql.Rows) ([]LCDMessage, error) {
+	defer rows.Close()
+	var list []LCDMessage
+	var t_created, t_mod, d_s, d_e, t_s, t_e int64
+	var v_days string
+	for rows.Next() {
+		var M LCDMessage
+		if err := rows.Scan(&M.MessageID, &M.AccountID, &M.Label, &M.Text, &M.GateName, &M.IsActive, &M.BacklightOn, &d_s, &d_e, &t_s, &t_e, &v_days, &t_created, &t_mod); err != nil {
+			return list, err
+		}
+		M.DateStart = D.ParseTime(d_s)
+		M.DateEnd = D.ParseTime(d_e)
+		M.TimeStart = D.ParseTime(t_s)
+		M.TimeEnd = D.ParseTime(t_e)
+		M.ValidDays = splitVDays(v_days)
+		M.TimeCreated = D.ParseTime(t_created)
+		M.TimeModified = D.ParseTime(t_mod)
+		list = append(list, M)
+	}
+	return list, nil
+}
+
+func (D *Database) LCDMessageInsert(M *LCDMessage) (*LCDMessage, error) {
+	q := `insert into lcd_message (account_id, label, message, gate_name, is_active, backlight_on, date_start, date_end, time_start, time_end, valid_days, time_created, time_modified) values
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		returning message_id;`
+	rslt, err := D.ExecSql(q,
+		M.AccountID,
+		M.Label,
+		M.Text,
+		M.GateName,
+		M.IsActive,
+		M.BacklightOn,
+		D.ToTime(M.DateStart),
+		D.ToTime(M.DateEnd),
+		D.ToTime(M.TimeStart),
+		D.ToTime(M.TimeEnd),
+		combineVDays(M.ValidDays),
+		D.TimeNow(),
+		D.TimeNow(),
+	)
+	if err != nil {
+		fmt.Println("Error Inserting LCDMessage:", err)
+		return nil, err
+	}
+	M.MessageID, err = rslt.LastInsertId()
+	return M, err
+}
+
+func (D *Database) LCDMessageUpdate(M *LCDMessage) (*LCDMessage, error) {
+	if M.MessageID < 1 {
+		return nil, fmt.Errorf("Missing Message ID for LCDMessageUpdate")
+	}
+	M.TimeModified = time.Now()
+	q := `update lcd_message set
+		label = ?,
+		message = ?,
+		gate_name = ?,
+		is_active = ?,
+		backlight_on = ?,
+		date_start = ?,
+		date_end = ?,
+		time_start = ?,
+		time_end = ?,
+		valid_days = ?,
+		time_modified = ?
+		where message_id = ?;`
+	_, err := D.ExecSql(q,
+		M.Label,
+		M.Text,
+		M.GateName,
+		M.IsActive,
+		M.BacklightOn,
+		D.ToTime(M.DateStart),
+		D.ToTime(M.DateEnd),
+		D.ToTime(M.TimeStart),
+		D.ToTime(M.TimeEnd),
+		combineVDays(M.ValidDays),
+		D.TimeNow(),
+		M.MessageID,
+	)
+	if err != nil {
+		fmt.Println("Error Updating LCDMessage:", err)
+		return nil, err
+	}
+	return M, nil
+}
+
+func (D *Database) LCDMessageSelectAll(messageId int64, activeOnly bool) ([]LCDMessage, error) {
+	//messageId = 0 means return everything
+	q := lcdMessageSelect
+	var conditions []string
+	var args []interface{}
+	if messageId > 0 {
+		conditions = append(conditions, "message_id = ?")
+		args = append(args, messageId)
+	}
+	if activeOnly {
+		conditions = append(conditions, "is_active = true")
+	}
+	if len(conditions) > 0 {
+		q += " where " + strings.Join(conditions, " and ")
+	}
+	//Newest first - the newest message scheduled for right now is the one shown
+	rows, err := D.QuerySql(q+" order by time_created desc;", args...)
+	if err != nil {
+		fmt.Println("Error Selecting LCDMessages:", err)
+		return nil, err
+	}
+	return D.parseLCDMessageRows(rows)
+}
+
+func (D *Database) PruneLCDMessages(before time.Time) error {
+	q := `DELETE from lcd_message where is_active = false and time_modified < ?;`
+	_, err := D.ExecSql(q, D.ToTime(before))
+	return err
+}
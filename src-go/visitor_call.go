@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VisitorCall is a visitor at the gate asking a resident from the directory to let them in.
+// Calls only live in memory - the approve links stop working after a restart.
+type VisitorCall struct {
+	CallID  int64
+	Gate    *GateConfig
+	Entry   DirectoryEntry
+	Picture []byte
+	Expires time.Time
+}
+
+var (
+	visitorCalls      = make(map[int64]*VisitorCall) //waiting for an answer
+	visitorCallNext   int64
+	visitorCallLocker sync.Mutex
+)
+
+const defaultVisitorLinkSecs = 300
+
+func visitorLinkSecs() int {
+	if CONFIG.VisitorLinkSecs > 0 {
+		return CONFIG.VisitorLinkSecs
+	}
+	return defaultVisitorLinkSecs
+}
+
+func (V VisitorCall) HasImage() bool {
+	return len(V.Picture) > 0
+}
+
+func (V VisitorCall) ImageBase64() string {
+	return base64.StdEncoding.EncodeToString(V.Picture)
+}
+
+// StartVisitorCall looks up the unit number and sends the approve link to the resident's contacts
+// Returns the label to show on the LCD
+func StartVisitorCall(gate *GateConfig, number string) (string, error) {
+	E, err := DB.DirectoryMatch(number, true)
+	if err != nil {
+		return "", err
+	}
+	if E == nil {
+		return "", errors.New(SiteMessage(Msg_UnknownUnit))
+	}
+	contacts, err := DB.ContactsForAccountNotify(E.AccountID)
+	if err != nil {
+		return "", err
+	}
+	if len(contacts) == 0 {
+		return "", errors.New(SiteMessage(Msg_NoAnswer))
+	}
+	go sendVisitorCall(gate, *E, contacts)
+	return E.Label, nil
+}
+
+func sendVisitorCall(gate *GateConfig, E DirectoryEntry, contacts []Contact) {
+	secs := visitorLinkSecs()
+	call := &VisitorCall{
+		Gate:    gate,
+		Entry:   E,
+		Picture: gate.TakePicture(),
+		Expires: time.Now().Add(time.Duration(secs) * time.Second),
+	}
+	visitorCallLocker.Lock()
+	for id, c := range visitorCalls {
+		if time.Now().After(c.Expires) {
+			delete(visitorCalls, id)
+		}
+	}
+	visitorCallNext++
+	call.CallID = visitorCallNext
+	visitorCalls[call.CallID] = call
+	visitorCallLocker.Unlock()
+
+	link := fmt.Sprintf("%s/visitor?t=%s", CONFIG.PublicBaseURL(), createVisitorToken(call.CallID, call.Expires))
+	for _, c := range contacts {
+		lang := c.MessageLanguage()
+		where := Message(lang, Msg_VisitorWhereGate)
+		if len(CONFIG.Gates) > 1 {
+			where = Message(lang, Msg_VisitorWhereNamed, gate.Name)
+		}
+		//Keep this short - text messages are limited to 150 characters
+		msg := Message(lang, Msg_VisitorCall, E.Label, where, (secs+59)/60, link)
+		if len(msg) > 150 {
+			msg = Message(lang, Msg_VisitorCallShort, link)
+		}
+		CONFIG.Email.SendEmailPicture(c.ContactEmail(), Message(lang, Msg_VisitorSubject, CONFIG.SiteName), msg, false, call.Picture)
+	}
+}
+
+// visitorSignature is the HMAC of the call ID and expiration (shortened to keep text messages small)
+func visitorSignature(callID int64, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(CONFIG.Auth.JwtSecret))
+	fmt.Fprintf(mac, "visitor:%d:%d", callID, expires)
+	return hex.EncodeToString(mac.Sum(nil))[:20]
+}
+
+// createVisitorToken signs the call ID for the approve link: "<id>-<expires>-<signature>"
+func createVisitorToken(callID int64, expires time.Time) string {
+	return fmt.Sprintf("%d-%d-%s", callID, expires.Unix(), visitorSignature(callID, expires.Unix()))
+}
+
+// VisitorCallFromToken checks the signature on the approve link and returns the call (still waiting for an answer)
+func VisitorCallFromToken(tok string) (*VisitorCall, error) {
+	invalid := fmt.Errorf("This link is invalid or has expired")
+	parts := strings.Split(tok, "-")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+	id, err1 := strconv.ParseInt(parts[0], 10, 64)
+	expires, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || !hmac.Equal([]byte(parts[2]), []byte(visitorSignature(id, expires))) {
+		return nil, invalid
+	}
+	if time.Now().Unix() > expires {
+		return nil, invalid
+	}
+	visitorCallLocker.Lock()
+	defer visitorCallLocker.Unlock()
+	call, ok := visitorCalls[id]
+	if !ok || call.Expires.Unix() != expires || time.Now().After(call.Expires) {
+		return nil, fmt.Errorf("This visitor has already been answered, or the link has expired")
+	}
+	return call, nil
+}
+
+// ApproveVisitorCall opens the gate for the visitor (each link only works once)
+func ApproveVisitorCall(tok string) (*VisitorCall, error) {
+	call, err := VisitorCallFromToken(tok)
+	if err != nil {
+		return nil, err
+	}
+	visitorCallLocker.Lock()
+	_, waiting := visitorCalls[call.CallID]
+	delete(visitorCalls, call.CallID)
+	visitorCallLocker.Unlock()
+	if !waiting {
+		return nil, fmt.Errorf("This visitor has already been answered, or the link has expired")
+	}
+	code := &AccountCode{
+		AccountID: call.Entry.AccountID,
+		Label:     "Visitor for " + call.Entry.Label,
+		CodeType:  CodeType_Visitor,
+		IsActive:  true,
+	}
+	return call, OpenGateAndNotify(call.Gate, nil, code)
+}
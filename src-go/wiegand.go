@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WiegandConfig is a card/fob reader (26 or 34 bit) wired to two GPIO inputs.
+// Both data lines idle high, and each bit is a short low pulse on D0 (0 bit) or D1 (1 bit):
+//
+//	[even parity] [data bits...] [odd parity]
+//
+// The leading parity bit covers the first half of the bits, the trailing one the second half.
+// The card number is the data bits between the parity bits (facility code + card number for 26-bit cards)
+type WiegandConfig struct {
+	D0     uint32 `json:"d0_gpio"`
+	D1     uint32 `json:"d1_gpio"`
+	PullUp bool   `json:"pull_up"` //turn on the pull-up resistors (if the reader does not have its own)
+	// Internal variables
+	gate *GateConfig   `json:"-"`
+	done chan struct{} `json:"-"`
+}
+
+// No pulses for this long means the card number is finished
+const wiegandFrameGap = 25 * time.Millisecond
+
+// Longest card number we will collect bits for
+const wiegandMaxBits = 64
+
+func (W *WiegandConfig) Start() error {
+	if W == nil {
+		return nil
+	}
+	if CONFIG.GPIO.BackendName() == GPIO_PINCTRL {
+		// pinctrl only polls the pins, and the pulses are far too short to catch that way
+		return fmt.Errorf("Wiegand reader needs the chardev GPIO backend")
+	}
+	for _, pin := range []uint32{W.D0, W.D1} {
+		if err := GPIO.SetInput(pin); err != nil {
+			return err
+		}
+		if W.PullUp {
+			if err := GPIO.SetPinUp(pin); err != nil {
+				return err
+			}
+		}
+	}
+	edges, err := GPIO.WatchEdges([]uint32{W.D0, W.D1})
+	if err != nil {
+		return err
+	}
+	W.done = make(chan struct{})
+	go readWiegandFrames(W.D0, W.D1, edges, W.done, W.cardRead)
+	return nil
+}
+
+func (W *WiegandConfig) Stop() {
+	if W == nil || W.done == nil {
+		return
+	}
+	close(W.done)
+	W.done = nil
+}
+
+func (W *WiegandConfig) cardRead(card string) {
+	fmt.Println("Card read at gate:", W.gate.Name)
+	err := CheckCardAndOpen(W.gate, card)
+	if err != nil {
+		fmt.Println("Card denied:", err)
+	}
+}
+
+// readWiegandFrames collects the pulses until there is a gap, then decodes them
+// The two lines are watched separately, so the pulses get put in order by their kernel timestamps
+// before they are turned into bits (the order they come in on the channel is not reliable).
+func readWiegandFrames(d0 uint32, d1 uint32, edges <-chan PinEvent, done <-chan struct{}, found func(card string)) {
+	var pulses []PinEvent
+	gap := time.NewTimer(time.Hour)
+	gap.Stop() //not needed until the first pulse
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-edges:
+			if !ok {
+				return
+			}
+			if ev.State != PIN_DOWN {
+				continue //the bit is on the start of the pulse
+			}
+			if ev.Pin != d0 && ev.Pin != d1 {
+				continue
+			}
+			pulses = append(pulses, ev)
+			if len(pulses) > wiegandMaxBits {
+				pulses = nil //noise on the lines
+			}
+			resetTimer(gap, wiegandFrameGap)
+		case <-gap.C:
+			card, err := DecodeWiegand(wiegandBits(pulses, d1))
+			pulses = nil
+			if err != nil {
+				fmt.Println("Wiegand read error:", err)
+				continue
+			}
+			found(card)
+		}
+	}
+}
+
+// wiegandBits puts the pulses in the order they happened and turns them into bits (D1 = 1)
+func wiegandBits(pulses []PinEvent, d1 uint32) []bool {
+	sort.SliceStable(pulses, func(i, j int) bool { return pulses[i].Stamp < pulses[j].Stamp })
+	bits := make([]bool, len(pulses))
+	for i, ev := range pulses {
+		bits[i] = ev.Pin == d1
+	}
+	return bits
+}
+
+func countBits(bits []bool) int {
+	count := 0
+	for _, b := range bits {
+		if b {
+			count++
+		}
+	}
+	return count
+}
+
+// DecodeWiegand checks the parity on a 26 or 34 bit frame and returns the card number
+func DecodeWiegand(bits []bool) (string, error) {
+	if len(bits) != 26 && len(bits) != 34 {
+		return "", fmt.Errorf("unsupported %d-bit frame", len(bits))
+	}
+	half := len(bits) / 2
+	if countBits(bits[:half])%2 != 0 || countBits(bits[half:])%2 != 1 {
+		return "", fmt.Errorf("parity error in %d-bit frame", len(bits))
+	}
+	var num uint64
+	for _, b := range bits[1 : len(bits)-1] {
+		num <<= 1
+		if b {
+			num |= 1
+		}
+	}
+	return strconv.FormatUint(num, 10), nil
+}
+
+// EncodeWiegand turns a card number into the bits a reader would send (26-bit if it fits, otherwise 34-bit)
+func EncodeWiegand(card string) ([]bool, error) {
+	num, err := strconv.ParseUint(card, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid card number: %s", card)
+	}
+	length := 26
+	if num >= 1<<24 {
+		length = 34
+	}
+	if num >= 1<<32 {
+		return nil, fmt.Errorf("card number too large: %s", card)
+	}
+	bits := make([]bool, length)
+	for i := length - 2; i >= 1; i-- {
+		bits[i] = num&1 == 1
+		num >>= 1
+	}
+	half := length / 2
+	bits[0] = countBits(bits[1:half])%2 == 1               //even parity
+	bits[length-1] = countBits(bits[half:length-1])%2 == 0 //odd parity
+	return bits, nil
+}
+
+func validateCardFormat(card string) bool {
+	_, err := EncodeWiegand(card)
+	return err == nil
+}
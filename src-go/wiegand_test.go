@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWiegandRoundTrip(t *testing.T) {
+	tests := []struct {
+		card string
+		bits int
+	}{
+		{"0", 26},
+		{"1234567", 26},    //facility 18, card 54919
+		{"16777215", 26},   //largest 24-bit number
+		{"16777216", 34},   //too big for 26 bits
+		{"4294967295", 34}, //largest 32-bit number
+	}
+	for _, tt := range tests {
+		bits, err := EncodeWiegand(tt.card)
+		if err != nil {
+			t.Fatalf("EncodeWiegand(%s): %v", tt.card, err)
+		}
+		if len(bits) != tt.bits {
+			t.Errorf("EncodeWiegand(%s) gave %d bits, want %d", tt.card, len(bits), tt.bits)
+		}
+		card, err := DecodeWiegand(bits)
+		if err != nil {
+			t.Errorf("DecodeWiegand(%s): %v", tt.card, err)
+		} else if card != tt.card {
+			t.Errorf("DecodeWiegand gave %s, want %s", card, tt.card)
+		}
+	}
+}
+
+func TestWiegandInvalid(t *testing.T) {
+	if _, err := EncodeWiegand("4294967296"); err == nil {
+		t.Error("EncodeWiegand accepted a card number over 32 bits")
+	}
+	if _, err := EncodeWiegand("12ab"); err == nil {
+		t.Error("EncodeWiegand accepted a card number which is not a number")
+	}
+	for _, length := range []int{0, 25, 27, 33, 35} {
+		if _, err := DecodeWiegand(make([]bool, length)); err == nil {
+			t.Errorf("DecodeWiegand accepted a %d-bit frame", length)
+		}
+	}
+	for _, card := range []string{"1234567", "305419896"} {
+		bits, _ := EncodeWiegand(card)
+		for _, flip := range []int{0, 1, len(bits) - 2, len(bits) - 1} {
+			bad := append([]bool(nil), bits...)
+			bad[flip] = !bad[flip]
+			if _, err := DecodeWiegand(bad); err == nil {
+				t.Errorf("DecodeWiegand(%s) missed the parity error with bit %d flipped", card, flip)
+			}
+		}
+	}
+}
+
+// Feeds the pulses for two cards through the frame reader, the way the GPIO backend sends them
+func TestReadWiegandFrames(t *testing.T) {
+	const d0, d1 = 5, 6
+	edges := make(chan PinEvent, 256)
+	done := make(chan struct{})
+	defer close(done)
+	found := make(chan string, 4)
+	go readWiegandFrames(d0, d1, edges, done, func(card string) { found <- card })
+
+	var stamp uint64
+	send := func(card string, swap bool) {
+		bits, err := EncodeWiegand(card)
+		if err != nil {
+			t.Fatalf("EncodeWiegand(%s): %v", card, err)
+		}
+		var events []PinEvent
+		for _, bit := range bits {
+			pin := uint32(d0)
+			if bit {
+				pin = d1
+			}
+			stamp += 2000000 //2ms between the bits
+			events = append(events,
+				PinEvent{Pin: pin, State: PIN_DOWN, Stamp: stamp},
+				PinEvent{Pin: pin, State: PIN_UP, Stamp: stamp + 50000},
+				PinEvent{Pin: 99, State: PIN_DOWN, Stamp: stamp}, //some other pin - ignored
+			)
+		}
+		if swap {
+			// The watcher for one line got behind the other one
+			events[0], events[3] = events[3], events[0]
+		}
+		for _, ev := range events {
+			edges <- ev
+		}
+	}
+	expect := func(want string) {
+		select {
+		case card := <-found:
+			if card != want {
+				t.Errorf("read card %s, want %s", card, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("card %s was never read", want)
+		}
+	}
+
+	send("1234567", false)
+	expect("1234567")
+	send("305419896", true)
+	expect("305419896")
+
+	// Two cards with no gap between them run together into one (invalid) frame
+	send("1234567", false)
+	send("1234567", false)
+	select {
+	case card := <-found:
+		t.Errorf("read card %s from two frames without a gap", card)
+	case <-time.After(5 * wiegandFrameGap):
+	}
+}